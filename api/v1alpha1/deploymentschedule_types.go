@@ -0,0 +1,84 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DeploymentScheduleSpec defines when Releases may be installed or upgraded
+type DeploymentScheduleSpec struct {
+	// Timezone the windows and blackouts are in, e.g. America/New_York, defaults to UTC
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// Windows are the only times changes are rolled out, any time outside the blackouts when there are none
+	// +optional
+	Windows []DeploymentWindow `json:"windows,omitempty"`
+
+	// Blackouts are change freezes, no changes are rolled out during them even inside a window
+	// +optional
+	Blackouts []Blackout `json:"blackouts,omitempty"`
+}
+
+// DeploymentWindow opens every time Start fires and stays open for Duration
+type DeploymentWindow struct {
+	// Start is a five field cron expression, e.g. "0 9 * * 2-4" for 09:00 tuesday to thursday
+	Start string `json:"start"`
+
+	// Duration the window stays open for, e.g. 7h
+	Duration string `json:"duration"`
+}
+
+// Blackout is a change freeze between two dates
+type Blackout struct {
+	// Start is a date (2006-01-02), a date and time (2006-01-02T15:04) or an RFC3339 timestamp
+	Start string `json:"start"`
+
+	// End is a date, which includes that whole day, a date and time or an RFC3339 timestamp
+	End string `json:"end"`
+
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DeploymentSchedule is the Schema for the DeploymentSchedules API.
+// Releases reference one with spec.deploymentSchedule, or a namespace applies one to all of its Releases
+// with the coveros.apps.genoa/deployment-schedule annotation.
+// +kubebuilder:printcolumn:name="timezone",type=string,JSONPath=.spec.timezone
+// +kubebuilder:printcolumn:name="age",type=date,JSONPath=.metadata.creationTimestamp
+type DeploymentSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DeploymentScheduleSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DeploymentScheduleList contains a list of DeploymentSchedule
+type DeploymentScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DeploymentSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DeploymentSchedule{}, &DeploymentScheduleList{})
+}
@@ -26,9 +26,31 @@ import (
 
 // ReleaseSpec defines the desired state of Release
 type ReleaseSpec struct {
+	// Suspend stops genoa from changing the helm release until it is set back to false.
+	// The coveros.apps.genoa/suspend annotation does the same without a spec change.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DeploymentSchedule is a DeploymentSchedule in the Release namespace that decides when changes are rolled out.
+	// Defaults to the schedule named by the coveros.apps.genoa/deployment-schedule annotation of the namespace the helm release
+	// is installed in, looked up in that namespace.
+	// +optional
+	DeploymentSchedule string `json:"deploymentSchedule,omitempty"`
+
+	// RequireApproval holds upgrades until a ReleaseApproval approves status.pendingChange.hash.
+	// Annotations on the Release do not approve a change, whoever can edit the Release could approve their own.
+	// +optional
+	RequireApproval bool `json:"requireApproval,omitempty"`
+
+	// DependsOn is a single Release that must be ready first, its namespace defaults to default.
+	// Deprecated: use Dependencies, kept so existing Releases keep working.
 	// +optional
 	DependsOn metav1.ObjectMeta `json:"dependsOn"`
 
+	// Dependencies lists Releases that must be ready before this one is installed or upgraded
+	// +optional
+	Dependencies []ReleaseReference `json:"dependencies,omitempty"`
+
 	// +optional
 	Atomic bool `json:"atomic"`
 
@@ -57,14 +79,298 @@ type ReleaseSpec struct {
 	// +optional
 	WaitTimeout int `json:"waitTimeout"`
 
+	// WaitMode decides how genoa waits when wait is set: Block holds a worker until helm sees the release healthy,
+	// Poll installs without waiting and checks the Deployments, StatefulSets, Jobs and PersistentVolumeClaims of the
+	// release on later reconciles. Defaults to Block. Remediation always blocks.
+	// +kubebuilder:validation:Enum=Block;Poll
+	// +optional
+	WaitMode string `json:"waitMode,omitempty"`
+
+	// TargetNamespace is the namespace the helm release is installed in, defaults to the namespace of the Release.
+	// Changing it, or releaseName, after the install stalls the Release instead of orphaning the installed helm release.
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// ReleaseName is the name of the helm release, defaults to the name of the Release
+	// +kubebuilder:validation:MaxLength=53
+	// +optional
+	ReleaseName string `json:"releaseName,omitempty"`
+
+	// CreateNamespace creates the namespace of the helm release when it is missing. Genoa owns the namespaces it creates
+	// and deletes them once the last Release in them is deleted.
+	// +optional
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+
+	// NamespaceMetadata are the labels and annotations of a namespace genoa creates
+	// +optional
+	NamespaceMetadata *NamespaceMetadata `json:"namespaceMetadata,omitempty"`
+
+	// DryRun only previews the install or upgrade, the diff of the rendered manifests is recorded in status.dryRun
 	// +optional
 	DryRun bool `json:"dryRun"`
 
 	// +optional
 	ValuesOverride Values `json:"values"`
 
+	// TemplateValues renders the strings in values as go templates before they are handed to helm. Templates can use
+	// {{ .Cluster.Name }}, {{ .Cluster.Domain }}, {{ .Release.Name }}, {{ .Release.Namespace }} and
+	// {{ configMapKey "<configmap>" "<key>" }} to read a ConfigMap in the Release namespace. Only strings using one
+	// of them are rendered, others such as {{ .Values.x }} are passed on for the chart's own tpl. Within a rendered
+	// string a template for the chart is kept with {{ "{{ .Values.x }}" }}.
+	// +optional
+	TemplateValues bool `json:"templateValues,omitempty"`
+
+	// MaxRetries is how many times failed installs and upgrades are retried before the Release stalls, 5 when unset
+	// and none when 0. A new generation or the coveros.apps.genoa/retry-now annotation starts over.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRetries *int `json:"maxRetries,omitempty"`
+
+	// Backoff spaces out the retries of failed installs and upgrades
+	// +optional
+	Backoff *Backoff `json:"backoff,omitempty"`
+
+	// +optional
+	Verify *ChartVerification `json:"verify,omitempty"`
+
+	// RollbackTo rolls the helm release back to this revision and holds it there.
+	// The Release stops following chart, version and values until it is cleared.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RollbackTo int `json:"rollbackTo,omitempty"`
+
+	// Remediation decides what happens to the helm release when an upgrade fails, nothing by default
+	// +optional
+	Remediation *Remediation `json:"remediation,omitempty"`
+
+	// DriftDetection compares the live objects of the helm release with its manifest on every resync
+	// +optional
+	DriftDetection *DriftDetection `json:"driftDetection,omitempty"`
+
+	// Adopt takes over an existing helm release. Genoa compares the chart and values of the helm release with the spec
+	// and does not change the release until they match or the differences are confirmed with the confirm-adoption annotation.
+	// Without an existing helm release the spec is installed as usual.
+	// +optional
+	Adopt bool `json:"adopt,omitempty"`
+
+	// OutOfBandPolicy decides what happens when the helm release gets a revision genoa did not create,
+	// e.g. a manual helm upgrade or helm rollback: Revert rolls back to the last revision genoa created,
+	// Adopt accepts the new revision and Alert reports it in the Drifted condition. Adopt and Alert leave the revision
+	// in place until the next change to the spec, which is applied over it. Defaults to Alert.
+	// +kubebuilder:validation:Enum=Revert;Adopt;Alert
+	// +optional
+	OutOfBandPolicy string `json:"outOfBandPolicy,omitempty"`
+
+	// PostRenderers patch the manifests rendered from the chart before they are applied, in order, on every install and upgrade
+	// +optional
+	PostRenderers []PostRenderer `json:"postRenderers,omitempty"`
+
+	// Test runs the helm tests of the chart after every install and upgrade
+	// +optional
+	Test *ReleaseTest `json:"test,omitempty"`
+
+	// Canary rolls upgrades out to a <name>-canary helm release first and promotes them once the analysis passes
+	// +optional
+	Canary *Canary `json:"canary,omitempty"`
+}
+
+// PostRenderer patches the rendered manifests of the chart. Patches are applied first, then the labels and annotations are added.
+type PostRenderer struct {
+	// +optional
+	Kustomize *KustomizePostRenderer `json:"kustomize,omitempty"`
+
+	// Labels are added to every object and to the pod templates of workloads, selectors are left alone
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to every object
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// KustomizePostRenderer applies inline kustomize patches
+type KustomizePostRenderer struct {
+	// +optional
+	Patches []KustomizePatch `json:"patches,omitempty"`
+}
+
+// KustomizePatch is a strategic merge patch, or a JSON 6902 patch when it is a list of operations
+type KustomizePatch struct {
+	// Target selects the objects to patch, a strategic merge patch without a target patches the object it names
+	// +optional
+	Target *KustomizePatchTarget `json:"target,omitempty"`
+
+	Patch string `json:"patch"`
+}
+
+// KustomizePatchTarget selects objects to patch, empty fields match anything
+type KustomizePatchTarget struct {
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// +optional
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// ReleaseTest runs the helm test hooks of the chart, a failing test fails the install or upgrade
+type ReleaseTest struct {
+	Enable bool `json:"enable"`
+
+	// Timeout for each test pod, 5m when unset
+	// +optional
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Canary installs the new chart version and values as a separate <name>-canary helm release taking a share of the traffic,
+// analyses it with Prometheus queries and then upgrades the primary release, or aborts and removes the canary
+type Canary struct {
+	// Weight is the share of traffic the canary takes, in percent. Defaults to 10.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Weight *int `json:"weight,omitempty"`
+
+	// WeightValue is the dotted path of the chart value the weight is set at for the canary release, canary.weight when unset
+	// +optional
+	WeightValue string `json:"weightValue,omitempty"`
+
+	Analysis CanaryAnalysis `json:"analysis"`
+}
+
+// CanaryAnalysis runs its checks every Interval and promotes the canary after Iterations passing runs
+type CanaryAnalysis struct {
+	// PrometheusAddress overrides the Prometheus genoa is configured with, e.g. http://prometheus.monitoring:9090
+	// +optional
+	PrometheusAddress string `json:"prometheusAddress,omitempty"`
+
+	// Interval between analysis runs, 1m when unset
+	// +optional
+	Interval string `json:"interval,omitempty"`
+
+	// Iterations is how many analysis runs have to pass before the canary is promoted, 5 when unset
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Iterations int `json:"iterations,omitempty"`
+
+	// +kubebuilder:validation:MinItems=1
+	Checks []CanaryCheck `json:"checks"`
+}
+
+// CanaryCheck is a Prometheus query whose value has to stay within Min and Max
+type CanaryCheck struct {
+	Name string `json:"name"`
+
+	// Query must return a scalar or a vector, the first sample is checked
+	Query string `json:"query"`
+
+	// Min is the lowest value that passes, e.g. "0.99"
+	// +optional
+	Min string `json:"min,omitempty"`
+
+	// Max is the highest value that passes, e.g. "0.05"
+	// +optional
+	Max string `json:"max,omitempty"`
+}
+
+const (
+	WaitModeBlock = "Block"
+	WaitModePoll  = "Poll"
+)
+
+const (
+	OutOfBandRevert = "Revert"
+	OutOfBandAdopt  = "Adopt"
+	OutOfBandAlert  = "Alert"
+)
+
+const (
+	DriftDetectionDetect  = "Detect"
+	DriftDetectionCorrect = "Correct"
+)
+
+// DriftDetection notices objects of the helm release that were changed or deleted outside of genoa
+type DriftDetection struct {
+	// Mode is Detect to only report drift in the Drifted condition, or Correct to also re-apply the release with an upgrade
+	// +kubebuilder:validation:Enum=Detect;Correct
+	Mode string `json:"mode"`
+}
+
+const (
+	RemediationNone      = "None"
+	RemediationRollback  = "Rollback"
+	RemediationReinstall = "Reinstall"
+)
+
+// Remediation repairs the helm release after a failed upgrade. Upgrades wait for the release to become healthy
+// when a remediation strategy is set, so unhealthy workloads count as failures too.
+type Remediation struct {
+	// Strategy is Rollback to roll back to the last successful revision, Reinstall to uninstall
+	// the release and install it again, or None
+	// +kubebuilder:validation:Enum=None;Rollback;Reinstall
+	Strategy string `json:"strategy"`
+
+	// Retries is how many times the upgrade is retried after remediating a failure.
+	// Once they run out the release is held at the remediated state until the Release spec changes.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Retries int `json:"retries"`
+}
+
+// ReleaseReference points at another Release
+type ReleaseReference struct {
+	Name string `json:"name"`
+
+	// Namespace defaults to the namespace of the Release holding the reference
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NamespaceMetadata is added to the namespaces genoa creates
+type NamespaceMetadata struct {
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Backoff waits Base after the first failure and doubles the wait for every further one, up to Max
+type Backoff struct {
+	// Base is the wait after the first failure, 10s when unset
 	// +optional
-	MaxRetries int `json:"maxRetries"`
+	Base string `json:"base,omitempty"`
+
+	// Max caps the wait, 10m when unset
+	// +optional
+	Max string `json:"max,omitempty"`
+
+	// JitterPercent adds up to this share of the wait at random, 10 when unset
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	JitterPercent *int `json:"jitterPercent,omitempty"`
+}
+
+// ChartVerification requires the chart to come with a valid provenance file signed by a key in the keyring secret
+type ChartVerification struct {
+	// KeyringSecretName is a secret in the Release namespace that holds the public keyring
+	KeyringSecretName string `json:"keyringSecretName,required"`
+
+	// KeyringSecretKey is the key within the secret that holds the keyring, defaults to pubring.gpg
+	// +optional
+	KeyringSecretKey string `json:"keyringSecretKey"`
 }
 
 type Values struct {
@@ -107,6 +413,383 @@ func (v *Values) UnmarshalJSON(data []byte) error {
 type ReleaseStatus struct {
 	FailureCount int  `json:"failureCount"`
 	Installed    bool `json:"installed"`
+
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// ObservedGeneration is the last Release generation the controller acted on
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastAppliedRevision is the chart version and values hash last applied successfully, as <version>/<values hash>,
+	// followed by /<post renderers hash> when the Release has post renderers
+	// +optional
+	LastAppliedRevision string `json:"lastAppliedRevision,omitempty"`
+
+	// HelmRevision is the revision number of the helm release
+	// +optional
+	HelmRevision int `json:"helmRevision,omitempty"`
+
+	// HelmReleaseName is the name of the installed helm release
+	// +optional
+	HelmReleaseName string `json:"helmReleaseName,omitempty"`
+
+	// HelmReleaseNamespace is the namespace the helm release is installed in
+	// +optional
+	HelmReleaseNamespace string `json:"helmReleaseNamespace,omitempty"`
+
+	// ChartName is the name of the chart actually installed
+	// +optional
+	ChartName string `json:"chartName,omitempty"`
+
+	// ChartVersion is the version of the chart actually installed
+	// +optional
+	ChartVersion string `json:"chartVersion,omitempty"`
+
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// RenderedValuesHash is the hash of the values after templating, the values helm actually gets
+	// +optional
+	RenderedValuesHash string `json:"renderedValuesHash,omitempty"`
+
+	// PostRenderersHash is the hash of the post renderers the helm release was last installed or upgraded with,
+	// helm does not keep them in the release. Empty when there were none.
+	// +optional
+	PostRenderersHash string `json:"postRenderersHash,omitempty"`
+
+	// LastProducedRevision is the last helm revision genoa created, newer revisions came from somewhere else
+	// +optional
+	LastProducedRevision int `json:"lastProducedRevision,omitempty"`
+
+	// OutOfBandHold is the out of band revision left in place by the Alert and Adopt policies
+	// +optional
+	OutOfBandHold *OutOfBandHold `json:"outOfBandHold,omitempty"`
+
+	// RolledBackTo is the spec.rollbackTo revision the controller already rolled back to
+	// +optional
+	RolledBackTo int `json:"rolledBackTo,omitempty"`
+
+	// NextRetryTime is the earliest time a failed install or upgrade is retried
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+
+	// LastRetryNow is the value of the retry-now annotation genoa last acted on
+	// +optional
+	LastRetryNow string `json:"lastRetryNow,omitempty"`
+
+	// PendingChange describes the change waiting for the next deployment window
+	// +optional
+	PendingChange *PendingChange `json:"pendingChange,omitempty"`
+
+	// History lists the most recent helm actions taken for this Release, oldest first
+	// +optional
+	History []ReleaseHistoryEntry `json:"history,omitempty"`
+
+	// Remediation tracks remediations of failed upgrades for the current generation
+	// +optional
+	Remediation *RemediationStatus `json:"remediation,omitempty"`
+
+	// DryRun is the preview of the change spec.dryRun holds back
+	// +optional
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// Readiness tracks the resources of a revision installed without waiting until they are all ready
+	// +optional
+	Readiness *ReadinessStatus `json:"readiness,omitempty"`
+
+	// Tests are the helm test results of the installed revision
+	// +optional
+	Tests *ReleaseTestStatus `json:"tests,omitempty"`
+
+	// Canary tracks the canary rollout of the latest change
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// Adoption compares the helm release spec.adopt takes over with the spec
+	// +optional
+	Adoption *AdoptionStatus `json:"adoption,omitempty"`
+}
+
+const (
+	AdoptionPhasePending = "Pending"
+	AdoptionPhaseAdopted = "Adopted"
+)
+
+// OutOfBandHold is an out of band helm revision genoa does not change until the spec does
+type OutOfBandHold struct {
+	Revision int `json:"revision"`
+
+	// Generation is the generation of the Release when the revision was found, a newer generation is applied over it
+	Generation int64 `json:"generation"`
+}
+
+// AdoptionStatus is the state of the adoption of an existing helm release: Pending until it matches the spec or
+// the differences are confirmed, then Adopted
+type AdoptionStatus struct {
+	Phase string `json:"phase"`
+
+	// Revision is the helm revision that was compared with the spec
+	Revision int `json:"revision"`
+
+	// Differences lists where the helm release differs from the spec: the chart, the version and the paths of the values
+	// +optional
+	Differences []string `json:"differences,omitempty"`
+
+	// Hash identifies the differences, confirm them by setting the confirm-adoption annotation to it
+	// +optional
+	Hash string `json:"hash,omitempty"`
+
+	Time metav1.Time `json:"time"`
+}
+
+// DryRunStatus is the diff between the manifests of the helm release and the ones the spec would render
+type DryRunStatus struct {
+	// Revision is the chart version and values hash that was previewed, as <version>/<values hash>
+	Revision string `json:"revision"`
+
+	// Summary counts the objects that would be added, changed and removed
+	Summary string `json:"summary"`
+
+	// Diff is the start of the diff, the whole diff is in the ConfigMap
+	// +optional
+	Diff string `json:"diff,omitempty"`
+
+	// +optional
+	Truncated bool `json:"truncated,omitempty"`
+
+	// ConfigMapName is the ConfigMap in the Release namespace holding the whole diff under the diff key
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	Time metav1.Time `json:"time"`
+}
+
+// ReadinessStatus is the progress of the resources of a helm revision towards ready
+type ReadinessStatus struct {
+	// Revision is the helm revision being waited on
+	Revision int `json:"revision"`
+
+	// Since is when the revision was installed, it fails once waitTimeout passes. A retry after a timeout waits
+	// another waitTimeout from when the timeout was reported.
+	Since metav1.Time `json:"since"`
+
+	// Ready counts the resources that are ready
+	Ready int `json:"ready"`
+
+	// Total counts the Deployments, StatefulSets, Jobs and PersistentVolumeClaims of the revision
+	Total int `json:"total"`
+
+	// Unready lists the first resources that are not ready and why
+	// +optional
+	Unready []string `json:"unready,omitempty"`
+}
+
+// ReleaseTestStatus is the outcome of the helm tests of a revision
+type ReleaseTestStatus struct {
+	// Revision is the helm revision that was tested
+	Revision int `json:"revision"`
+
+	Passed bool `json:"passed"`
+
+	// +optional
+	Results []ReleaseTestResult `json:"results,omitempty"`
+}
+
+// ReleaseTestResult is the outcome of a single test pod
+type ReleaseTestResult struct {
+	Name string `json:"name"`
+
+	// Phase is Succeeded, Failed, Running or Unknown
+	Phase string `json:"phase"`
+
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+const (
+	CanaryPhaseAnalyzing = "Analyzing"
+	CanaryPhasePromoting = "Promoting"
+	CanaryPhaseSucceeded = "Succeeded"
+	CanaryPhaseAborted   = "Aborted"
+)
+
+// CanaryStatus is the state of a canary rollout: Analyzing, then Promoting and Succeeded, or Aborted
+type CanaryStatus struct {
+	Phase string `json:"phase"`
+
+	// Revision is the chart version and values hash being rolled out, as <version>/<values hash>
+	Revision string `json:"revision"`
+
+	// ReleaseName is the helm release of the canary
+	ReleaseName string `json:"releaseName"`
+
+	// PassedIterations counts the analysis runs that passed
+	// +optional
+	PassedIterations int `json:"passedIterations,omitempty"`
+
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// +optional
+	LastAnalysisTime *metav1.Time `json:"lastAnalysisTime,omitempty"`
+
+	// LastResults are the check results of the last analysis run
+	// +optional
+	LastResults []CanaryCheckResult `json:"lastResults,omitempty"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// CanaryCheckResult is the outcome of a single check, values are kept as strings since CRDs avoid floats
+type CanaryCheckResult struct {
+	Name string `json:"name"`
+
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	Passed bool `json:"passed"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PendingChange is a change genoa has not rolled out yet
+type PendingChange struct {
+	// Hash identifies the change from the installed chart, version, values and post renderers to the ones in the spec,
+	// approvals name it so editing the Release again invalidates them
+	// +optional
+	Hash string `json:"hash,omitempty"`
+
+	// +optional
+	Chart string `json:"chart,omitempty"`
+
+	ChartVersion string `json:"chartVersion"`
+
+	ValuesHash string `json:"valuesHash"`
+
+	// ChangedValues lists the paths of the values that change, not the values themselves
+	// +optional
+	ChangedValues []string `json:"changedValues,omitempty"`
+
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// NotBefore is the earliest time the change will be rolled out
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+}
+
+// RemediationStatus counts the remediations performed for a Release generation
+type RemediationStatus struct {
+	Strategy string `json:"strategy"`
+
+	Generation int64 `json:"generation"`
+
+	Attempts int `json:"attempts"`
+}
+
+const (
+	HistoryOutcomeInstalled  = "Installed"
+	HistoryOutcomeUpgraded   = "Upgraded"
+	HistoryOutcomeRolledBack = "RolledBack"
+	HistoryOutcomeFailed     = "Failed"
+	HistoryOutcomeAdopted    = "Adopted"
+)
+
+// ReleaseHistoryEntry records a single install, upgrade or rollback of the helm release
+type ReleaseHistoryEntry struct {
+	// Revision is the helm revision the action produced, 0 when it failed before helm recorded one
+	// +optional
+	Revision int `json:"revision,omitempty"`
+
+	ChartVersion string `json:"chartVersion"`
+
+	ValuesHash string `json:"valuesHash"`
+
+	Timestamp metav1.Time `json:"timestamp"`
+
+	Outcome string `json:"outcome"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// ConditionReady is true when the helm release is installed and matches the Release spec
+	ConditionReady = "Ready"
+	// ConditionReconciling is true while the controller is working towards the Release spec
+	ConditionReconciling = "Reconciling"
+	// ConditionStalled is true when the controller gave up on the Release until its spec changes
+	ConditionStalled = "Stalled"
+	// ConditionDependenciesReady is true when every Release this one depends on is ready
+	ConditionDependenciesReady = "DependenciesReady"
+	// ConditionDrifted is true when the cluster no longer matches what genoa last applied
+	ConditionDrifted = "Drifted"
+	// ConditionSuspended is true while reconciliation is suspended
+	ConditionSuspended = "Suspended"
+	// ConditionValuesRendered is true when the values templates of the Release rendered
+	ConditionValuesRendered = "ValuesRendered"
+)
+
+// Condition mirrors the upstream metav1.Condition, which is not available in this apimachinery version
+type Condition struct {
+	Type string `json:"type"`
+
+	Status metav1.ConditionStatus `json:"status"`
+
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+
+	Reason string `json:"reason"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// GetCondition returns the condition of the given type, or nil when it was never set
+func (s *ReleaseStatus) GetCondition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsConditionTrue reports whether the condition of the given type is set and true
+func (s *ReleaseStatus) IsConditionTrue(conditionType string) bool {
+	condition := s.GetCondition(conditionType)
+	return condition != nil && condition.Status == metav1.ConditionTrue
+}
+
+// SetCondition adds or updates a condition, the transition time only moves when its status changes
+func (s *ReleaseStatus) SetCondition(conditionType string, status metav1.ConditionStatus, reason, message string, generation int64) {
+	existing := s.GetCondition(conditionType)
+	if existing == nil {
+		s.Conditions = append(s.Conditions, Condition{
+			Type:               conditionType,
+			Status:             status,
+			ObservedGeneration: generation,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		})
+		return
+	}
+	if existing.Status != status {
+		existing.LastTransitionTime = metav1.Now()
+	}
+	existing.Status = status
+	existing.ObservedGeneration = generation
+	existing.Reason = reason
+	existing.Message = message
 }
 
 // +kubebuilder:object:root=true
@@ -116,6 +799,12 @@ type ReleaseStatus struct {
 // +kubebuilder:printcolumn:name="release-namespace",type=string,JSONPath=.metadata.namespace
 // +kubebuilder:printcolumn:name="chart",type=string,JSONPath=.spec.chart
 // +kubebuilder:printcolumn:name="chart-version",type=string,JSONPath=.spec.version
+// +kubebuilder:printcolumn:name="ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="status",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="suspended",type=string,JSONPath=`.status.conditions[?(@.type=="Suspended")].status`
+// +kubebuilder:printcolumn:name="revision",type=integer,JSONPath=.status.helmRevision
+// +kubebuilder:printcolumn:name="target-namespace",type=string,JSONPath=.status.helmReleaseNamespace,priority=1
+// +kubebuilder:printcolumn:name="installed-version",type=string,JSONPath=.status.chartVersion,priority=1
 // +kubebuilder:printcolumn:name="age",type=date,JSONPath=.metadata.creationTimestamp
 // +kubebuilder:subresource:status
 type Release struct {
@@ -0,0 +1,57 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReleaseApprovalSpec approves one pending change of a Release
+type ReleaseApprovalSpec struct {
+	// ReleaseName is the Release in the same namespace the approval is for
+	ReleaseName string `json:"releaseName"`
+
+	// ChangeHash is the status.pendingChange.hash of the Release being approved
+	ChangeHash string `json:"changeHash"`
+}
+
+// +kubebuilder:object:root=true
+
+// ReleaseApproval is the Schema for the ReleaseApprovals API.
+// Creating them can be limited with RBAC to the people allowed to approve changes.
+// +kubebuilder:printcolumn:name="release",type=string,JSONPath=.spec.releaseName
+// +kubebuilder:printcolumn:name="change",type=string,JSONPath=.spec.changeHash
+// +kubebuilder:printcolumn:name="age",type=date,JSONPath=.metadata.creationTimestamp
+type ReleaseApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ReleaseApprovalSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ReleaseApprovalList contains a list of ReleaseApproval
+type ReleaseApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ReleaseApproval `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ReleaseApproval{}, &ReleaseApprovalList{})
+}
@@ -0,0 +1,113 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// genoa-adopt prints a Release manifest that adopts an existing helm release in the current cluster, with the chart,
+// version and values the helm release was installed with. Commit it to the repo genoa follows to take the release over.
+// The values are copied as they are, secrets included, --without-values leaves them out.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
+	"github.com/ghodss/yaml"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"os"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func main() {
+	var helmNamespace string
+	var releaseNamespace string
+	var repoAlias string
+	var withoutValues bool
+
+	flag.StringVar(&helmNamespace, "namespace", "default", "Namespace of the helm release")
+	flag.StringVar(&releaseNamespace, "release-namespace", "", "Namespace of the Release, defaults to the namespace of the helm release")
+	flag.StringVar(&repoAlias, "repo", "", "Helm repo alias the chart comes from, as in config.helmRepos")
+	flag.BoolVar(&withoutValues, "without-values", false,
+		"Leave the values of the helm release out of the manifest, e.g. when they hold secrets. Add them back before adopting, "+
+			"genoa upgrades the release to the values of the manifest once it is adopted")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <helm release name>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+
+	if flag.NArg() != 1 || repoAlias == "" {
+		flag.Usage()
+		exit(fmt.Errorf("a helm release name and --repo are required"))
+	}
+
+	helmV3, errCreatingActionConfig := v3.NewActionConfig(helmNamespace, ctrl.GetConfigOrDie())
+	if errCreatingActionConfig != nil {
+		exit(errCreatingActionConfig)
+	}
+	releaseInfo, errGettingRelease := helmV3.GetRelease(flag.Arg(0))
+	if errGettingRelease != nil {
+		exit(fmt.Errorf("helm release %s/%s: %v", helmNamespace, flag.Arg(0), errGettingRelease))
+	}
+	manifest, errBuildingManifest := v3.ReleaseManifest(releaseInfo, repoAlias, releaseNamespace)
+	if errBuildingManifest != nil {
+		exit(errBuildingManifest)
+	}
+	if withoutValues {
+		manifest.Spec.ValuesOverride.V = map[string]interface{}{}
+		fmt.Fprintln(os.Stderr, "values of the helm release were left out, add them before committing the manifest")
+	} else if len(releaseInfo.Config) > 0 {
+		fmt.Fprintln(os.Stderr, "warning: the manifest holds the values of the helm release as they are, passwords and tokens included. "+
+			"Move secrets out of them before committing it to git, or use --without-values")
+	}
+
+	out, errPrinting := manifestYAML(manifest)
+	if errPrinting != nil {
+		exit(errPrinting)
+	}
+	fmt.Print(string(out))
+}
+
+// manifestYAML prints a manifest the way it is written by hand, without status and server set fields
+func manifestYAML(manifest interface{}) ([]byte, error) {
+	raw, errMarshalling := json.Marshal(manifest)
+	if errMarshalling != nil {
+		return nil, errMarshalling
+	}
+	fields := map[string]interface{}{}
+	if errUnmarshalling := json.Unmarshal(raw, &fields); errUnmarshalling != nil {
+		return nil, errUnmarshalling
+	}
+	delete(fields, "status")
+	if metadata, ok := fields["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	if spec, ok := fields["spec"].(map[string]interface{}); ok {
+		// the spec fields that are not omitempty only repeat their defaults
+		for key, value := range spec {
+			if value == false || value == float64(0) {
+				delete(spec, key)
+			}
+		}
+	}
+	return yaml.Marshal(fields)
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,168 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// genoa-bundle builds an offline chart bundle for air gapped clusters from Release manifests and/or the Releases
+// in the current cluster. Copy the bundle into the directory genoa is started with --chart-mirror-dir.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	coverosv1alpha1 "github.com/coveros/genoa/api/v1alpha1"
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
+	"github.com/ghodss/yaml"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"os"
+	"path/filepath"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sort"
+	"strings"
+)
+
+func main() {
+	var outDir string
+	var customRepoConfigPath string
+	var fromCluster bool
+
+	flag.StringVar(&outDir, "out", "chart-bundle", "Directory to write the bundle into")
+	flag.StringVar(&customRepoConfigPath, "custom-helm-repos-file", "", "Your own custom helm repo files")
+	flag.BoolVar(&fromCluster, "from-cluster", false, "Also bundle the charts of every Release in the current cluster")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [release manifest files or dirs...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+
+	var releases []coverosv1alpha1.Release
+	for _, path := range flag.Args() {
+		releasesFromPath, errReading := readReleaseManifests(path)
+		if errReading != nil {
+			exit(errReading)
+		}
+		releases = append(releases, releasesFromPath...)
+	}
+	if fromCluster {
+		releasesFromCluster, errListing := listReleases()
+		if errListing != nil {
+			exit(errListing)
+		}
+		releases = append(releases, releasesFromCluster...)
+	}
+	if len(releases) == 0 {
+		flag.Usage()
+		exit(fmt.Errorf("no releases found to bundle"))
+	}
+
+	repoOptions := map[string]v3.RepoOptions{}
+	if customRepoConfigPath != "" {
+		if errAddingCustomRepos := v3.AddReposFromFile(customRepoConfigPath); errAddingCustomRepos != nil {
+			exit(errAddingCustomRepos)
+		}
+		var errLoadingRepoOptions error
+		if repoOptions, errLoadingRepoOptions = v3.LoadRepoOptionsFromFile(customRepoConfigPath); errLoadingRepoOptions != nil {
+			exit(errLoadingRepoOptions)
+		}
+	}
+
+	charts := chartsToBundle(releases)
+	for _, chart := range charts {
+		fmt.Printf("%s/%s %s\n", chart.Repo, chart.Chart, chart.Version)
+	}
+	if errBuilding := v3.BuildBundle(charts, repoOptions, outDir); errBuilding != nil {
+		exit(errBuilding)
+	}
+	fmt.Printf("bundled %d charts into %s\n", len(charts), outDir)
+}
+
+// chartsToBundle returns each distinct chart version the releases need, sorted so bundles are reproducible
+func chartsToBundle(releases []coverosv1alpha1.Release) []v3.BundleChart {
+	seen := map[v3.BundleChart]bool{}
+	var charts []v3.BundleChart
+	for _, release := range releases {
+		repoWithChartName := strings.SplitN(release.Spec.Chart, "/", 2)
+		if len(repoWithChartName) != 2 {
+			fmt.Fprintf(os.Stderr, "skipping %s/%s, chart %q is not in repo/chart form\n", release.GetNamespace(), release.GetName(), release.Spec.Chart)
+			continue
+		}
+		chart := v3.BundleChart{Repo: repoWithChartName[0], Chart: repoWithChartName[1], Version: release.Spec.Version}
+		if !seen[chart] {
+			seen[chart] = true
+			charts = append(charts, chart)
+		}
+	}
+	sort.Slice(charts, func(i, j int) bool {
+		return fmt.Sprint(charts[i]) < fmt.Sprint(charts[j])
+	})
+	return charts
+}
+
+// readReleaseManifests reads every Release from a yaml file, or from all yaml files under a dir
+func readReleaseManifests(path string) ([]coverosv1alpha1.Release, error) {
+	var releases []coverosv1alpha1.Release
+	errWalking := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if ext := filepath.Ext(file); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		raw, errReading := ioutil.ReadFile(file)
+		if errReading != nil {
+			return errReading
+		}
+		for _, doc := range bytes.Split(raw, []byte("\n---")) {
+			release := coverosv1alpha1.Release{}
+			if errParsing := yaml.Unmarshal(doc, &release); errParsing != nil {
+				return fmt.Errorf("%s: %v", file, errParsing)
+			}
+			if release.Kind == "Release" && release.Spec.Chart != "" {
+				releases = append(releases, release)
+			}
+		}
+		return nil
+	})
+	return releases, errWalking
+}
+
+func listReleases() ([]coverosv1alpha1.Release, error) {
+	scheme := runtime.NewScheme()
+	if err := coverosv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	k8sClient, errCreatingClient := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if errCreatingClient != nil {
+		return nil, errCreatingClient
+	}
+
+	releaseList := &coverosv1alpha1.ReleaseList{}
+	if errListing := k8sClient.List(context.TODO(), releaseList); errListing != nil {
+		return nil, errListing
+	}
+	return releaseList.Items, nil
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
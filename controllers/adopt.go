@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path"
+	"strings"
+)
+
+// adoptionPending is true while spec.adopt holds back changes to a helm release genoa did not create
+func adoptionPending(cr *v1alpha1.Release) bool {
+	if !cr.Spec.Adopt || cr.Status.LastProducedRevision > 0 {
+		// a release genoa installed or already took over is managed as usual
+		return false
+	}
+	return cr.Status.Adoption == nil || cr.Status.Adoption.Phase != v1alpha1.AdoptionPhaseAdopted
+}
+
+// adoptionDifferences lists where the helm release differs from the spec, the values are compared with the
+// values the release was installed with, not the defaults of the chart
+func adoptionDifferences(cr *v1alpha1.Release, releaseInfo *release.Release, values map[string]interface{}) []string {
+	var differences []string
+	if releaseInfo.Chart != nil && releaseInfo.Chart.Metadata != nil {
+		if specChart := path.Base(cr.Spec.Chart); releaseInfo.Chart.Metadata.Name != specChart {
+			differences = append(differences, fmt.Sprintf("chart: %v installed, %v in spec", releaseInfo.Chart.Metadata.Name, specChart))
+		}
+		if releaseInfo.Chart.Metadata.Version != cr.Spec.Version {
+			differences = append(differences, fmt.Sprintf("version: %v installed, %v in spec", releaseInfo.Chart.Metadata.Version, cr.Spec.Version))
+		}
+	}
+	for _, changed := range changedValues(releaseInfo.Config, values, "") {
+		differences = append(differences, "values."+changed)
+	}
+	return differences
+}
+
+// checkAdoption compares an existing helm release with the spec adopting it. It returns true once the release is
+// adopted, either because it matches the spec or because the differences were confirmed with the confirm-adoption annotation.
+func (r *ReleaseReconciler) checkAdoption(cr *v1alpha1.Release, releaseInfo *release.Release, values map[string]interface{}) bool {
+	differences := adoptionDifferences(cr, releaseInfo, values)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v/%v: %v", releaseNamespace(cr), releaseInfo.Version, strings.Join(differences, ", "))))
+	hash := hex.EncodeToString(sum[:])[:16]
+	previous := cr.Status.Adoption
+	adoption := &v1alpha1.AdoptionStatus{
+		Phase:       v1alpha1.AdoptionPhasePending,
+		Revision:    releaseInfo.Version,
+		Differences: differences,
+		Hash:        hash,
+		Time:        metav1.Now(),
+	}
+	if previous != nil && previous.Hash == hash {
+		adoption.Time = previous.Time
+	}
+
+	confirmed := cr.GetAnnotations()[utils.ConfirmAdoptionAnnotation] == hash
+	if len(differences) == 0 || confirmed {
+		message := fmt.Sprintf("adopted helm release %v/%v at revision %v", releaseNamespace(cr), helmReleaseName(cr), releaseInfo.Version)
+		if confirmed && len(differences) > 0 {
+			message = fmt.Sprintf("%v, confirmed differences: %v", message, strings.Join(differences, ", "))
+		}
+		r.Log.Info(fmt.Sprintf("%v/%v %v", cr.GetNamespace(), cr.GetName(), message))
+		adoption.Phase = v1alpha1.AdoptionPhaseAdopted
+		cr.Status.Adoption = adoption
+		recordHistory(cr, releaseInfo, v1alpha1.HistoryOutcomeAdopted, message)
+		r.notifyAdoption(cr, cNotifyLib.Success, fmt.Sprintf("Release adopted an existing helm release :handshake: %v", message))
+		return true
+	}
+
+	message := fmt.Sprintf("helm release %v/%v differs from the spec: %v; update the Release to match or set the %v annotation to %v",
+		releaseNamespace(cr), helmReleaseName(cr), strings.Join(differences, ", "), utils.ConfirmAdoptionAnnotation, hash)
+	if previous == nil || previous.Hash != hash {
+		r.notifyAdoption(cr, cNotifyLib.Warning, fmt.Sprintf("Release is waiting to adopt an existing helm release :raised_hand: %v", message))
+	}
+	r.Log.Info(fmt.Sprintf("%v/%v %v", cr.GetNamespace(), cr.GetName(), message))
+	cr.Status.Adoption = adoption
+	generation := cr.GetGeneration()
+	cr.Status.ObservedGeneration = generation
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, "AdoptionPending", message, generation)
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionFalse, "AdoptionPending", "", generation)
+	return false
+}
+
+func (r *ReleaseReconciler) notifyAdoption(cr *v1alpha1.Release, eventType cNotifyLib.NotifyEventType, reason string) {
+	r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+		Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+		Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+		EventType: eventType,
+		Fields: map[string]string{
+			"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+			"Namespace": cr.GetNamespace(),
+			"Reason":    reason},
+	})
+}
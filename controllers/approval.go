@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
+)
+
+// newPendingChange describes the change from the installed helm release, nil when not installed yet, to the Release spec
+// with its rendered values
+func newPendingChange(cr *v1alpha1.Release, releaseInfo *release.Release, values map[string]interface{}, reason string) *v1alpha1.PendingChange {
+	fromRevision := "none"
+	var fromValues map[string]interface{}
+	if releaseInfo != nil && releaseInfo.Chart != nil && releaseInfo.Chart.Metadata != nil {
+		fromValues = releaseInfo.Config
+		fromRevision = releaseInfo.Chart.Metadata.Name + "-" + appliedRevision(releaseInfo.Chart.Metadata.Version, fromValues, cr.Status.PostRenderersHash)
+	}
+	// everything the upgrade applies, so an approval does not carry over to a different chart or post renderer
+	toValuesHash := valuesHash(values)
+	toRevision := cr.Spec.Chart + "-" + appliedRevision(cr.Spec.Version, values, postRenderersHash(cr))
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s -> %s", fromRevision, toRevision)))
+	return &v1alpha1.PendingChange{
+		Hash:          hex.EncodeToString(sum[:])[:16],
+		Chart:         cr.Spec.Chart,
+		ChartVersion:  cr.Spec.Version,
+		ValuesHash:    toValuesHash,
+		ChangedValues: changedValues(fromValues, values, ""),
+		Reason:        reason,
+	}
+}
+
+// changedValues lists the paths of values that were added, removed or changed, sorted
+func changedValues(from, to map[string]interface{}, prefix string) []string {
+	var paths []string
+	for key, toValue := range to {
+		fromValue, ok := from[key]
+		fromMap, fromIsMap := fromValue.(map[string]interface{})
+		toMap, toIsMap := toValue.(map[string]interface{})
+		switch {
+		case ok && fromIsMap && toIsMap:
+			paths = append(paths, changedValues(fromMap, toMap, prefix+key+".")...)
+		case !ok || !reflect.DeepEqual(fromValue, toValue):
+			paths = append(paths, prefix+key)
+		}
+	}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			paths = append(paths, prefix+key)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// checkApproval reports whether the upgrade to the Release spec was approved.
+// Until it is the change waits in status for a ReleaseApproval naming its hash. Only ReleaseApprovals count, creating
+// them is limited with RBAC while anyone able to change the Release could also approve it through the Release itself.
+func (r *ReleaseReconciler) checkApproval(cr *v1alpha1.Release, releaseInfo *release.Release, values map[string]interface{}) (bool, error) {
+	if !cr.Spec.RequireApproval {
+		return true, nil
+	}
+	pendingChange := newPendingChange(cr, releaseInfo, values, "")
+	approvals := &v1alpha1.ReleaseApprovalList{}
+	if err := r.Client.List(context.TODO(), approvals, client.InNamespace(cr.GetNamespace())); err != nil {
+		return false, err
+	}
+	for _, approval := range approvals.Items {
+		if approval.Spec.ReleaseName == cr.GetName() && approval.Spec.ChangeHash == pendingChange.Hash {
+			r.Log.Info(fmt.Sprintf("%v/%v change %v approved by %v", cr.GetNamespace(), cr.GetName(), pendingChange.Hash, approval.GetName()))
+			return true, nil
+		}
+	}
+
+	pendingChange.Reason = fmt.Sprintf("waiting for approval of change %v", pendingChange.Hash)
+	if cr.Status.PendingChange == nil || cr.Status.PendingChange.Hash != pendingChange.Hash {
+		r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+			Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+			Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+			EventType: cNotifyLib.Warning,
+			Fields: map[string]string{
+				"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+				"Namespace": cr.GetNamespace(),
+				"Reason":    fmt.Sprintf("Release upgrade is waiting for approval of change %v :raised_hand: changed values: %v", pendingChange.Hash, pendingChange.ChangedValues)},
+		})
+	}
+	r.Log.Info(fmt.Sprintf("%v/%v %v", cr.GetNamespace(), cr.GetName(), pendingChange.Reason))
+	cr.Status.PendingChange = pendingChange
+	markReconciling(cr, "AwaitingApproval", pendingChange.Reason)
+	return false, nil
+}
+
+// approvedRelease maps a ReleaseApproval to the Release it approves
+func approvedRelease(o handler.MapObject) []reconcile.Request {
+	approval, ok := o.Object.(*v1alpha1.ReleaseApproval)
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: approval.GetNamespace(), Name: approval.Spec.ReleaseName}}}
+}
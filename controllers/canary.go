@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/analysis"
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCanaryWeight      = 10
+	defaultCanaryWeightValue = "canary.weight"
+	defaultCanaryInterval    = time.Minute
+	defaultCanaryIterations  = 5
+	// helmReleaseNameMaxLength is the longest release name helm accepts
+	helmReleaseNameMaxLength = 53
+)
+
+// canaryReleaseName is the helm release of the canary, long release names are shortened to fit the helm limit
+func canaryReleaseName(cr *v1alpha1.Release) string {
+	return utils.NameWithSuffix(helmReleaseName(cr), "-canary", helmReleaseNameMaxLength)
+}
+
+func canaryInProgress(cr *v1alpha1.Release) bool {
+	return cr.Status.Canary != nil &&
+		(cr.Status.Canary.Phase == v1alpha1.CanaryPhaseAnalyzing || cr.Status.Canary.Phase == v1alpha1.CanaryPhasePromoting)
+}
+
+func canaryInterval(cr *v1alpha1.Release) time.Duration {
+	if interval, err := time.ParseDuration(cr.Spec.Canary.Analysis.Interval); err == nil && interval > 0 {
+		return interval
+	}
+	return defaultCanaryInterval
+}
+
+func canaryIterations(cr *v1alpha1.Release) int {
+	if cr.Spec.Canary.Analysis.Iterations > 0 {
+		return cr.Spec.Canary.Analysis.Iterations
+	}
+	return defaultCanaryIterations
+}
+
+// canaryValues are the rendered values with the canary traffic weight set
+func canaryValues(cr *v1alpha1.Release, renderedValues map[string]interface{}) map[string]interface{} {
+	values := (&v1alpha1.Values{V: renderedValues}).DeepCopy().V
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	weight, weightValue := defaultCanaryWeight, defaultCanaryWeightValue
+	if cr.Spec.Canary.Weight != nil {
+		weight = *cr.Spec.Canary.Weight
+	}
+	if cr.Spec.Canary.WeightValue != "" {
+		weightValue = cr.Spec.Canary.WeightValue
+	}
+	setValue(values, strings.Split(weightValue, "."), weight)
+	return values
+}
+
+// setValue sets a nested value, replacing anything in the way that is not a map
+func setValue(values map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := values[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			values[key] = next
+		}
+		values = next
+	}
+	values[path[len(path)-1]] = value
+}
+
+// canaryQuerier returns the Prometheus the Release analyses its canary with
+func (r *ReleaseReconciler) canaryQuerier(cr *v1alpha1.Release) (analysis.Querier, error) {
+	address := cr.Spec.Canary.Analysis.PrometheusAddress
+	if address == "" {
+		address = r.PrometheusAddress
+	}
+	if address == "" {
+		return nil, errors.New("no prometheus address, set spec.canary.analysis.prometheusAddress or --prometheus-address")
+	}
+	return &analysis.Prometheus{Address: address}, nil
+}
+
+// runCanary moves the canary rollout of the spec one step forward. It returns true once the canary passed
+// its analysis and the primary release may be upgraded, otherwise the result to return from Reconcile after saving the status.
+func (r *ReleaseReconciler) runCanary(cr *v1alpha1.Release, actionConfig *v3.HelmV3, chartPath string, values map[string]interface{}) (bool, ctrl.Result, error) {
+	revision := appliedRevision(cr.Spec.Version, values, postRenderersHash(cr))
+	if cr.Status.Canary == nil || cr.Status.Canary.Revision != revision {
+		if errStarting := r.startCanary(cr, actionConfig, chartPath, revision, values); errStarting != nil {
+			return false, ctrl.Result{}, errStarting
+		}
+		if cr.Status.Canary.Phase == v1alpha1.CanaryPhaseAborted {
+			return false, ctrl.Result{}, nil
+		}
+		return false, ctrl.Result{RequeueAfter: canaryInterval(cr)}, nil
+	}
+
+	canary := cr.Status.Canary
+	switch canary.Phase {
+	case v1alpha1.CanaryPhaseAborted:
+		r.Log.Info(fmt.Sprintf("%v/%v canary of %v was aborted, holding until the spec changes", cr.GetNamespace(), cr.GetName(), revision))
+		return false, ctrl.Result{}, nil
+	case v1alpha1.CanaryPhasePromoting, v1alpha1.CanaryPhaseSucceeded:
+		return true, ctrl.Result{}, nil
+	}
+
+	interval := canaryInterval(cr)
+	lastRun := canary.StartTime
+	if canary.LastAnalysisTime != nil {
+		lastRun = canary.LastAnalysisTime
+	}
+	if lastRun != nil && time.Since(lastRun.Time) < interval {
+		return false, ctrl.Result{RequeueAfter: interval - time.Since(lastRun.Time)}, nil
+	}
+
+	var results []analysis.Result
+	querier, errGettingQuerier := r.canaryQuerier(cr)
+	if errGettingQuerier != nil {
+		results = []analysis.Result{{Name: "prometheus", Message: errGettingQuerier.Error()}}
+	} else {
+		var checks []analysis.Check
+		for _, check := range cr.Spec.Canary.Analysis.Checks {
+			checks = append(checks, analysis.Check{Name: check.Name, Query: check.Query, Min: check.Min, Max: check.Max})
+		}
+		results = analysis.Run(querier, checks)
+	}
+	now := metav1.Now()
+	canary.LastAnalysisTime = &now
+	canary.LastResults = nil
+	var failed []string
+	for _, result := range results {
+		canary.LastResults = append(canary.LastResults, v1alpha1.CanaryCheckResult{
+			Name:    result.Name,
+			Value:   strconv.FormatFloat(result.Value, 'g', -1, 64),
+			Passed:  result.Passed,
+			Message: result.Message,
+		})
+		if !result.Passed {
+			failed = append(failed, fmt.Sprintf("%v: %v", result.Name, result.Message))
+		}
+	}
+
+	if !analysis.Passed(results) {
+		return false, ctrl.Result{}, r.abortCanary(cr, actionConfig, fmt.Sprintf("analysis failed, %v", strings.Join(failed, ", ")))
+	}
+
+	canary.PassedIterations++
+	if canary.PassedIterations < canaryIterations(cr) {
+		canary.Message = fmt.Sprintf("%v of %v analysis runs passed", canary.PassedIterations, canaryIterations(cr))
+		r.Log.Info(fmt.Sprintf("%v/%v canary %v", cr.GetNamespace(), cr.GetName(), canary.Message))
+		markReconciling(cr, "CanaryAnalyzing", canary.Message)
+		return false, ctrl.Result{RequeueAfter: interval}, nil
+	}
+	canary.Phase = v1alpha1.CanaryPhasePromoting
+	canary.Message = fmt.Sprintf("all %v analysis runs passed, promoting", canary.PassedIterations)
+	r.Log.Info(fmt.Sprintf("%v/%v canary %v", cr.GetNamespace(), cr.GetName(), canary.Message))
+	return true, ctrl.Result{}, nil
+}
+
+// startCanary installs or upgrades the canary helm release to the spec and starts analysing it
+func (r *ReleaseReconciler) startCanary(cr *v1alpha1.Release, actionConfig *v3.HelmV3, chartPath, revision string, values map[string]interface{}) error {
+	now := metav1.Now()
+	cr.Status.Canary = &v1alpha1.CanaryStatus{
+		Phase:       v1alpha1.CanaryPhaseAnalyzing,
+		Revision:    revision,
+		ReleaseName: canaryReleaseName(cr),
+		StartTime:   &now,
+	}
+	r.Log.Info(fmt.Sprintf("%v/%v starting canary %v of %v", cr.GetNamespace(), cr.GetName(), canaryReleaseName(cr), revision))
+
+	var errDeploying error
+	if _, errGettingCanary := actionConfig.GetRelease(canaryReleaseName(cr)); errors.Is(errGettingCanary, driver.ErrReleaseNotFound) {
+		installOpts := getReleaseInstallOptions(cr)
+		installOpts.ReleaseName = canaryReleaseName(cr)
+		_, errDeploying = actionConfig.InstallRelease(chartPath, installOpts, canaryValues(cr, values))
+	} else if errGettingCanary != nil {
+		errDeploying = errGettingCanary
+	} else {
+		upgradeOpts := getReleaseUpgradeOptions(cr)
+		upgradeOpts.ReleaseName = canaryReleaseName(cr)
+		_, errDeploying = actionConfig.UpgradeRelease(chartPath, upgradeOpts, canaryValues(cr, values))
+	}
+	if errDeploying != nil {
+		return r.abortCanary(cr, actionConfig, fmt.Sprintf("canary failed to deploy, %v", errDeploying))
+	}
+
+	cr.Status.Canary.Message = fmt.Sprintf("analysing %v", canaryReleaseName(cr))
+	r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+		Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+		Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+		EventType: cNotifyLib.Success,
+		Fields: map[string]string{
+			"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+			"Namespace": cr.GetNamespace(),
+			"Reason":    fmt.Sprintf("Canary %v deployed, analysing it :hatching_chick:", canaryReleaseName(cr))},
+	})
+	markReconciling(cr, "CanaryAnalyzing", cr.Status.Canary.Message)
+	return nil
+}
+
+// abortCanary removes the canary helm release and holds the primary release until the spec changes
+func (r *ReleaseReconciler) abortCanary(cr *v1alpha1.Release, actionConfig *v3.HelmV3, message string) error {
+	r.Log.Info(fmt.Sprintf("%v/%v aborting canary: %v", cr.GetNamespace(), cr.GetName(), message))
+	if _, errUninstalling := actionConfig.UninstallRelease(canaryReleaseName(cr)); errUninstalling != nil {
+		return errUninstalling
+	}
+	cr.Status.Canary.Phase = v1alpha1.CanaryPhaseAborted
+	cr.Status.Canary.Message = message
+	r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+		Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+		Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+		EventType: cNotifyLib.Failure,
+		Fields: map[string]string{
+			"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+			"Namespace": cr.GetNamespace(),
+			"Reason":    fmt.Sprintf("Canary aborted, the release was not upgraded :hatched_chick: :fire: %v", message)},
+	})
+	generation := cr.GetGeneration()
+	cr.Status.ObservedGeneration = generation
+	cr.Status.LastError = message
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, "CanaryAborted", message, generation)
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionFalse, "CanaryAborted", "", generation)
+	cr.Status.SetCondition(v1alpha1.ConditionStalled, metav1.ConditionTrue, "CanaryAborted",
+		"the canary failed, update the Release spec to try again", generation)
+	return nil
+}
+
+// finishCanary removes the canary helm release once the primary release runs the promoted change
+func (r *ReleaseReconciler) finishCanary(cr *v1alpha1.Release, actionConfig *v3.HelmV3) error {
+	if _, errUninstalling := actionConfig.UninstallRelease(canaryReleaseName(cr)); errUninstalling != nil {
+		return errUninstalling
+	}
+	cr.Status.Canary.Phase = v1alpha1.CanaryPhaseSucceeded
+	cr.Status.Canary.Message = "promoted to the primary release"
+	return nil
+}
+
+// abandonCanary removes a canary that no longer has anything to promote, e.g. the spec went back to the installed version
+func (r *ReleaseReconciler) abandonCanary(cr *v1alpha1.Release, actionConfig *v3.HelmV3, message string) error {
+	r.Log.Info(fmt.Sprintf("%v/%v removing canary: %v", cr.GetNamespace(), cr.GetName(), message))
+	if _, errUninstalling := actionConfig.UninstallRelease(canaryReleaseName(cr)); errUninstalling != nil {
+		return errUninstalling
+	}
+	cr.Status.Canary = nil
+	return nil
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/utils"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+)
+
+// dependsOnIndexField indexes Releases by the <namespace>/<name> of every Release they depend on
+const dependsOnIndexField = ".spec.dependsOn"
+
+func dependencyKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// legacyDependencyNamespace is where the deprecated spec.dependsOn looks for a dependency without a namespace
+const legacyDependencyNamespace = "default"
+
+// dependencyKeys resolves spec.dependencies, a dependency without a namespace lives next to the Release.
+// The deprecated spec.dependsOn keeps its old default namespace.
+func dependencyKeys(cr *v1alpha1.Release) []string {
+	var keys []string
+	if legacy := cr.Spec.DependsOn; legacy.GetName() != "" {
+		namespace := legacy.GetNamespace()
+		if namespace == "" {
+			namespace = legacyDependencyNamespace
+		}
+		keys = append(keys, dependencyKey(namespace, legacy.GetName()))
+	}
+	for _, dependency := range cr.Spec.Dependencies {
+		namespace := dependency.Namespace
+		if namespace == "" {
+			namespace = cr.GetNamespace()
+		}
+		keys = append(keys, dependencyKey(namespace, dependency.Name))
+	}
+	return keys
+}
+
+func indexDependencies(obj runtime.Object) []string {
+	return dependencyKeys(obj.(*v1alpha1.Release))
+}
+
+// isReleaseReady is true once a Release is ready for its current spec
+func isReleaseReady(cr *v1alpha1.Release) bool {
+	return cr.Status.IsConditionTrue(v1alpha1.ConditionReady) && cr.Status.ObservedGeneration == cr.GetGeneration()
+}
+
+// checkDependencies reports whether every Release in spec.dependsOn is ready, and if not the reason why
+func (r *ReleaseReconciler) checkDependencies(cr *v1alpha1.Release) (bool, string, string, error) {
+	cycle, errFindingCycle := utils.FindCycle(dependencyKey(cr.GetNamespace(), cr.GetName()), func(key string) ([]string, error) {
+		dependency, errGettingDependency := r.getReleaseByKey(key)
+		if errGettingDependency != nil || dependency == nil {
+			return nil, errGettingDependency
+		}
+		return dependencyKeys(dependency), nil
+	})
+	if errFindingCycle != nil {
+		return false, "", "", errFindingCycle
+	}
+	if cycle != nil {
+		return false, "DependencyCycle", fmt.Sprintf("dependency cycle %v", strings.Join(cycle, " -> ")), nil
+	}
+
+	for _, key := range dependencyKeys(cr) {
+		dependency, errGettingDependency := r.getReleaseByKey(key)
+		if errGettingDependency != nil {
+			return false, "", "", errGettingDependency
+		}
+		if dependency == nil {
+			return false, "DependencyNotFound", fmt.Sprintf("waiting for %v to be created", key), nil
+		}
+		if !isReleaseReady(dependency) {
+			return false, "DependencyNotReady", fmt.Sprintf("waiting for %v to be ready", key), nil
+		}
+	}
+	return true, "DependenciesReady", "", nil
+}
+
+// getReleaseByKey returns the Release at <namespace>/<name>, or nil when there is none
+func (r *ReleaseReconciler) getReleaseByKey(key string) (*v1alpha1.Release, error) {
+	namespaceAndName := strings.SplitN(key, "/", 2)
+	release := &v1alpha1.Release{}
+	errGettingRelease := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespaceAndName[0], Name: namespaceAndName[1]}, release)
+	if apiErrors.IsNotFound(errGettingRelease) {
+		return nil, nil
+	}
+	if errGettingRelease != nil {
+		return nil, errGettingRelease
+	}
+	return release, nil
+}
+
+// dependentsOf maps a Release to the Releases that depend on it, so they are reconciled as soon as it becomes ready
+func (r *ReleaseReconciler) dependentsOf(o handler.MapObject) []reconcile.Request {
+	dependents := &v1alpha1.ReleaseList{}
+	if err := r.Client.List(context.TODO(), dependents,
+		client.MatchingFields{dependsOnIndexField: dependencyKey(o.Meta.GetNamespace(), o.Meta.GetName())}); err != nil {
+		r.Log.Error(err, fmt.Sprintf("failed to list dependents of %v/%v", o.Meta.GetNamespace(), o.Meta.GetName()))
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, dependent := range dependents.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: dependent.GetNamespace(), Name: dependent.GetName()}})
+	}
+	return requests
+}
+
+// dependencyChanged passes updates that matter to dependents, readiness flips and spec changes that may add a cycle
+func dependencyChanged(e event.UpdateEvent) bool {
+	oldRelease, okOld := e.ObjectOld.(*v1alpha1.Release)
+	newRelease, okNew := e.ObjectNew.(*v1alpha1.Release)
+	if !okOld || !okNew {
+		return false
+	}
+	return isReleaseReady(oldRelease) != isReleaseReady(newRelease) || oldRelease.GetGeneration() != newRelease.GetGeneration()
+}
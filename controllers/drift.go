@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// maxDriftedObjectsInMessage keeps the Drifted condition readable for releases with many objects
+const maxDriftedObjectsInMessage = 10
+
+const (
+	reasonDriftCorrected = "DriftCorrected"
+	// reasonDriftPersists is drift an upgrade did not correct, it is reported but not corrected again
+	reasonDriftPersists = "DriftPersists"
+)
+
+// checkDrift compares the live objects of the helm release with its manifest and records the result in the Drifted condition.
+// It returns true when the drift should be corrected with an upgrade.
+func (r *ReleaseReconciler) checkDrift(cr *v1alpha1.Release, actionConfig *v3.HelmV3, releaseInfo *release.Release) bool {
+	if cr.Spec.DriftDetection == nil {
+		return false
+	}
+	wasDrifted := cr.Status.IsConditionTrue(v1alpha1.ConditionDrifted)
+	var previousReason, previousMessage string
+	if previous := cr.Status.GetCondition(v1alpha1.ConditionDrifted); previous != nil {
+		previousReason, previousMessage = previous.Reason, previous.Message
+	}
+	driftedObjects, errDetectingDrift := actionConfig.DetectDrift(releaseInfo)
+	if errDetectingDrift != nil {
+		r.Log.Error(errDetectingDrift, fmt.Sprintf("%v/%v failed to check for drift", cr.GetNamespace(), cr.GetName()))
+		cr.Status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionUnknown, "DriftCheckFailed", errDetectingDrift.Error(), cr.GetGeneration())
+		return false
+	}
+	markDrift(cr, driftedObjects)
+	if len(driftedObjects) == 0 {
+		return false
+	}
+
+	correct := cr.Spec.DriftDetection.Mode == v1alpha1.DriftDetectionCorrect
+	message := driftMessage(driftedObjects)
+	if correct && (previousReason == reasonDriftCorrected || previousReason == reasonDriftPersists) && previousMessage == message {
+		// the last upgrade did not correct it, e.g. a field the api server or another controller keeps changing,
+		// so upgrading again would only create revisions until the drift changes or goes away
+		cr.Status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionTrue, reasonDriftPersists, message, cr.GetGeneration())
+		if previousReason == reasonDriftCorrected {
+			r.Log.Info(fmt.Sprintf("%v/%v drift persists after re-applying the release, not correcting it again: %v", cr.GetNamespace(), cr.GetName(), message))
+			r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+				Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+				Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+				EventType: cNotifyLib.Warning,
+				Fields: map[string]string{
+					"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+					"Namespace": cr.GetNamespace(),
+					"Reason":    fmt.Sprintf("Release drift came back after re-applying it, not correcting it again :warning: %v", message)},
+			})
+		}
+		return false
+	}
+	if !wasDrifted {
+		action := "reporting it"
+		if correct {
+			action = "re-applying the release"
+		}
+		r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+			Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+			Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+			EventType: cNotifyLib.Failure,
+			Fields: map[string]string{
+				"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+				"Namespace": cr.GetNamespace(),
+				"Reason":    fmt.Sprintf("Release drifted from its manifest, %v :warning: %v", action, message)},
+		})
+	}
+	return correct
+}
+
+// markDrift records the objects that no longer match the release manifest
+func markDrift(cr *v1alpha1.Release, driftedObjects []v3.DriftedObject) {
+	if len(driftedObjects) == 0 {
+		cr.Status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionFalse, "NoDrift", "", cr.GetGeneration())
+		return
+	}
+	cr.Status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionTrue, "ObjectsChanged", driftMessage(driftedObjects), cr.GetGeneration())
+}
+
+// markDriftCorrected records the drift an upgrade re-applied, drift that is still there afterwards is not corrected again
+func markDriftCorrected(cr *v1alpha1.Release) {
+	message := ""
+	if drifted := cr.Status.GetCondition(v1alpha1.ConditionDrifted); drifted != nil {
+		message = drifted.Message
+	}
+	cr.Status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionFalse, reasonDriftCorrected, message, cr.GetGeneration())
+}
+
+func driftMessage(driftedObjects []v3.DriftedObject) string {
+	var changes []string
+	for i, driftedObject := range driftedObjects {
+		if i == maxDriftedObjectsInMessage {
+			changes = append(changes, fmt.Sprintf("and %d more", len(driftedObjects)-i))
+			break
+		}
+		changes = append(changes, driftedObject.String())
+	}
+	return strings.Join(changes, "; ")
+}
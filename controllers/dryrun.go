@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg"
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/release"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strings"
+	"time"
+)
+
+const (
+	// maxDryRunDiffInStatus keeps the Release object small, the whole diff goes to a ConfigMap
+	maxDryRunDiffInStatus = 4 * 1024
+	// maxDryRunDiffInConfigMap stays well below the 1MiB object size limit
+	maxDryRunDiffInConfigMap = 900 * 1024
+	// maxDryRunDiffInNotification fits in a chat message
+	maxDryRunDiffInNotification = 2000
+)
+
+func dryRunConfigMapName(cr *v1alpha1.Release) string {
+	return cr.GetName() + "-dry-run"
+}
+
+// dryRun previews the install or upgrade of the spec without applying it and records the manifest diff.
+// releaseInfo is nil when the helm release does not exist yet. Every change is previewed once.
+func (r *ReleaseReconciler) dryRun(cr *v1alpha1.Release, actionConfig *v3.HelmV3, releaseInfo *release.Release,
+	repoAlias, chartName string, values map[string]interface{}, originalStatus *v1alpha1.ReleaseStatus) (ctrl.Result, error) {
+	revision := appliedRevision(cr.Spec.Version, values, postRenderersHash(cr))
+	if cr.Status.DryRun != nil && cr.Status.DryRun.Revision == revision {
+		if !reflect.DeepEqual(originalStatus, &cr.Status) {
+			return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+		}
+		return ctrl.Result{}, nil
+	}
+	if releaseInfo != nil && isReleasePending(releaseInfo) {
+		// helm refuses to even preview an upgrade while another operation is in progress
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	chartPath, releaseChart, errPullingChart := r.pullChart(cr, repoAlias, chartName, cr.Spec.Version, actionConfig)
+	if errPullingChart != nil {
+		if _, ok := errPullingChart.(pkg.ErrorHelmRepoNeedsRefresh); ok {
+			return ctrl.Result{Requeue: true}, actionConfig.RefreshRepoIndex(repoAlias, r.getRepoOptions(repoAlias))
+		}
+		if reason, msg := pullChartFailureReason(errPullingChart); reason != "" {
+			return r.chartPullFailed(cr, reason, msg, errPullingChart)
+		}
+		return ctrl.Result{}, errPullingChart
+	}
+	defer releaseChart()
+
+	var currentManifest string
+	var previewedRelease *release.Release
+	var errPreviewing error
+	if releaseInfo == nil {
+		previewedRelease, errPreviewing = actionConfig.InstallRelease(chartPath, getReleaseInstallOptions(cr), values)
+	} else {
+		currentManifest = releaseInfo.Manifest
+		previewedRelease, errPreviewing = actionConfig.UpgradeRelease(chartPath, getReleaseUpgradeOptions(cr), values)
+	}
+	if errPreviewing != nil {
+		markFailed(cr, "DryRunFailed", errPreviewing)
+		return r.retryLater(cr, errPreviewing)
+	}
+	diff, errDiffing := v3.DiffManifests(currentManifest, previewedRelease.Manifest)
+	if errDiffing != nil {
+		return ctrl.Result{}, errDiffing
+	}
+	if errSavingDiff := r.saveDryRunDiff(cr, diff.Diff); errSavingDiff != nil {
+		return ctrl.Result{}, errSavingDiff
+	}
+
+	statusDiff, truncated := truncateDiff(diff.Diff, maxDryRunDiffInStatus)
+	cr.Status.DryRun = &v1alpha1.DryRunStatus{
+		Revision:      revision,
+		Summary:       diff.String(),
+		Diff:          statusDiff,
+		Truncated:     truncated,
+		ConfigMapName: dryRunConfigMapName(cr),
+		Time:          metav1.Now(),
+	}
+	r.Log.Info(fmt.Sprintf("%v/%v dry run of %v-%v: %v", cr.GetNamespace(), cr.GetName(), cr.Spec.Chart, cr.Spec.Version, diff))
+
+	notificationDiff, _ := truncateDiff(diff.Diff, maxDryRunDiffInNotification)
+	r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+		Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+		Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+		EventType: cNotifyLib.Warning,
+		Fields: map[string]string{
+			"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+			"Namespace": cr.GetNamespace(),
+			"Reason":    fmt.Sprintf("Dry run, nothing was applied :mag: %v", diff),
+			"Diff":      fmt.Sprintf("```%v```", notificationDiff)},
+	})
+	markDryRun(cr, diff.String())
+	return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+}
+
+// saveDryRunDiff keeps the whole diff in a ConfigMap owned by the Release
+func (r *ReleaseReconciler) saveDryRunDiff(cr *v1alpha1.Release, diff string) error {
+	configMap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: dryRunConfigMapName(cr), Namespace: cr.GetNamespace()}}
+	_, errSaving := controllerutil.CreateOrUpdate(context.TODO(), r.Client, configMap, func() error {
+		configMapDiff, _ := truncateDiff(diff, maxDryRunDiffInConfigMap)
+		configMap.Data = map[string]string{"diff": configMapDiff}
+		return controllerutil.SetControllerReference(cr, configMap, r.Scheme)
+	})
+	return errSaving
+}
+
+// truncateDiff cuts a diff after the last whole line that fits
+func truncateDiff(diff string, max int) (string, bool) {
+	if len(diff) <= max {
+		return diff, false
+	}
+	truncated := diff[:max]
+	if i := strings.LastIndex(truncated, "\n"); i >= 0 {
+		truncated = truncated[:i+1]
+	}
+	return truncated, true
+}
+
+// markDryRun records a previewed change, the Release is not ready since nothing was applied
+func markDryRun(cr *v1alpha1.Release, summary string) {
+	generation := cr.GetGeneration()
+	cr.Status.FailureCount = 0
+	cr.Status.NextRetryTime = nil
+	cr.Status.ObservedGeneration = generation
+	cr.Status.LastError = ""
+	message := fmt.Sprintf("dry run: %v", summary)
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, "DryRun", message, generation)
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionFalse, "DryRun", "", generation)
+	cr.Status.SetCondition(v1alpha1.ConditionStalled, metav1.ConditionFalse, "DryRun", "", generation)
+}
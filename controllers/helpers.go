@@ -4,83 +4,167 @@ import (
 	"context"
 	"fmt"
 	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg"
 	v3 "github.com/coveros/genoa/pkg/helm/v3"
 	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/release"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"strings"
 	"time"
 )
 
 func (r *ReleaseReconciler) cleanup(cr *v1alpha1.Release, actionConfig *v3.HelmV3) error {
-	var deleteNamespace bool
+	// first, delete the helm release, unless it belongs to another Release
+	owner, errFindingOwner := r.helmReleaseOwner(cr)
+	if errFindingOwner != nil {
+		return errFindingOwner
+	}
+	if owner == nil && managesHelmRelease(cr) {
+		if _, errUninstallingRelease := actionConfig.UninstallRelease(helmReleaseName(cr)); errUninstallingRelease != nil {
+			return errUninstallingRelease
+		}
+		if cr.Status.Canary != nil {
+			if _, errUninstallingCanary := actionConfig.UninstallRelease(cr.Status.Canary.ReleaseName); errUninstallingCanary != nil {
+				return errUninstallingCanary
+			}
+		}
+	}
 
-	// first, delete the helm release
-	if _, errUninstallingRelease := actionConfig.UninstallRelease(cr.GetName()); errUninstallingRelease != nil {
-		return errUninstallingRelease
+	// second, delete the namespace when genoa owns it and this was the last Release in it
+	if errDeletingNamespace := r.deleteNamespaceIfUnused(cr); errDeletingNamespace != nil {
+		return errDeletingNamespace
 	}
 
-	// second, check if we can delete the namespace
-	if val, ok := cr.GetAnnotations()[utils.AutoDeleteNamespaceAnnotation]; ok && strings.ToLower(val) == "true" {
-		deleteNamespace = true
+	// finally, remove finalizer from CR
+	return utils.RemoveFinalizer(utils.ReleaseFinalizer, r.Client, cr)
+}
+
+func (r *ReleaseReconciler) pullChart(cr *v1alpha1.Release, repoAlias, chartName, version string, actionConfig *v3.HelmV3) (string, func(), error) {
+
+	// find keyrings if either the release or the repo require a signed chart
+	keyrings, errGettingKeyrings := r.getKeyrings(cr, repoAlias)
+	if errGettingKeyrings != nil {
+		return "", nil, errGettingKeyrings
 	}
 
-	// third, remove finalizer from CR
-	if errRemovingFinalizer := utils.RemoveFinalizer(utils.ReleaseFinalizer, r.Client, cr); errRemovingFinalizer != nil {
-		return errRemovingFinalizer
+	// air gapped clusters never reach out to the remote repos
+	if r.ChartMirror != nil {
+		return r.ChartMirror.PullChart(r.ChartCache, repoAlias, chartName, version, keyrings)
 	}
 
-	// fourth, check if other Releases exist in the same namespace
-	hrList := &v1alpha1.ReleaseList{}
-	if errGettingHrList := r.Client.List(context.TODO(), hrList); errGettingHrList != nil {
-		return errGettingHrList
+	// find repo url and TLS settings from repo config file
+	repoEntry, errLookingUpRepo := actionConfig.GetRepoEntryFromRepoConfig(repoAlias)
+	if errLookingUpRepo != nil {
+		return "", nil, errLookingUpRepo
 	}
 
-	/**
-	Finally, if we are allowed to delete the namespace AND there are NO OTHER Release's within, delete it.
-	However this wont work if
+	// download chart into the shared chart cache
+	chartPath, releaseChart, errDownloadingChart := actionConfig.PullChart(r.ChartCache, repoEntry, r.getRepoOptions(repoAlias),
+		chartName, version, keyrings)
+	if errDownloadingChart != nil {
+		return "", nil, errDownloadingChart
+	}
 
-	- A Release called "foo" gets installed in "foo" namespace with deleteNamespace annotation
-	- A Release called "bar" gets installed in "bar" namespace without deleteNamespace annotation
-	- Or if user has installed in their own custom existing namespace
+	// return chart path
+	return chartPath, releaseChart, nil
+}
 
-	- if "foo" Release gets deleted, there will be 1 Release left in that namespace, but when
-		"bar" release gets deleted it wont have the annotation to delete the namespace
+// getRepoOptions returns the genoa specific settings of a repo, repos without any get the defaults
+func (r *ReleaseReconciler) getRepoOptions(repoAlias string) v3.RepoOptions {
+	return r.RepoOptions[strings.ToLower(repoAlias)]
+}
 
-	TODO: Maybe a better approach is to add a namespace label as soon as Release with deleteRelease annotation gets
-	installed in a namespace -- this way we can keep a track of which namespace is supposed to be deleted such that
-	when all Releases are gone, we can finally delete the namespace
+// getKeyrings returns the public keyrings a chart must be signed with, none when no verification is required.
+// A repo that requires signed charts is always verified against its own keyring, a Release verify spec adds its keyring
+// on top so it cannot point verification at a keyring of its choosing.
+func (r *ReleaseReconciler) getKeyrings(cr *v1alpha1.Release, repoAlias string) ([][]byte, error) {
+	var keyrings [][]byte
+	if repoOpts := r.getRepoOptions(repoAlias); repoOpts.VerifyProvenance {
+		if repoOpts.KeyringSecret == "" {
+			return nil, pkg.ErrorChartVerificationFailed{Message: fmt.Sprintf("%v repo requires signed charts but has no keyringSecret", repoAlias)}
+		}
+		// never looked up in the Release namespace, its owner could supply their own signing key
+		secretName, errParsing := repoOpts.KeyringSecretName()
+		if errParsing != nil {
+			return nil, pkg.ErrorChartVerificationFailed{Message: errParsing.Error()}
+		}
+		keyring, errGettingKeyring := r.getKeyring(secretName, utils.DefaultKeyringSecretKey)
+		if errGettingKeyring != nil {
+			return nil, errGettingKeyring
+		}
+		keyrings = append(keyrings, keyring)
+	}
 
-	*/
-	if deleteNamespace && len(hrList.Items) == 0 {
-		//if errDeletingNamespace := r.Client.Delete(context.TODO(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: cr.GetNamespace()}}); errDeletingNamespace != nil {
-		//	if errors.IsNotFound(errDeletingNamespace) {
-		//		return nil
-		//	}
-		//	return errDeletingNamespace
-		//}
+	if cr.Spec.Verify != nil {
+		secretKey := utils.DefaultKeyringSecretKey
+		if cr.Spec.Verify.KeyringSecretKey != "" {
+			secretKey = cr.Spec.Verify.KeyringSecretKey
+		}
+		keyring, errGettingKeyring := r.getKeyring(types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.Spec.Verify.KeyringSecretName}, secretKey)
+		if errGettingKeyring != nil {
+			return nil, errGettingKeyring
+		}
+		keyrings = append(keyrings, keyring)
 	}
-	return nil
+	return keyrings, nil
 }
 
-func (r *ReleaseReconciler) pullChart(namespace, crName, repoAlias, chartName, version string, actionConfig *v3.HelmV3) (string, error) {
-
-	// find repo url from repo config file
-	repoUrl, username, password, errLookingUpRepo := actionConfig.GetRepoUrlFromRepoConfig(repoAlias)
-	if errLookingUpRepo != nil {
-		return "", errLookingUpRepo
+// getKeyring reads a public keyring from a secret
+func (r *ReleaseReconciler) getKeyring(secretName types.NamespacedName, secretKey string) ([]byte, error) {
+	keyringSecret := &v1.Secret{}
+	if errGettingSecret := r.Client.Get(context.TODO(), secretName, keyringSecret); errGettingSecret != nil {
+		return nil, errGettingSecret
+	}
+	keyring, ok := keyringSecret.Data[secretKey]
+	if !ok || len(keyring) == 0 {
+		return nil, pkg.ErrorChartVerificationFailed{Message: fmt.Sprintf("%v secret has no keyring under %v", secretName, secretKey)}
 	}
+	return keyring, nil
+}
 
-	// download chart
-	chartPath, errDownloadingChart := actionConfig.DownloadChart(repoUrl, repoAlias,
-		chartName, version,
-		username, password,
-		fmt.Sprintf("%v-%v", namespace, crName))
-	if errDownloadingChart != nil {
-		return "", errDownloadingChart
+// pullChartFailureReason maps a chart pull error to a status reason and a notification message,
+// errors that are not known to genoa return an empty reason
+func pullChartFailureReason(errPullingChart error) (string, string) {
+	switch errPullingChart.(type) {
+	case pkg.ErrorRepoAuthFailed:
+		return "RepoAuthFailed", "Chart repo rejected the credentials :closed_lock_with_key:"
+	case pkg.ErrorRepoNotFound:
+		return "ChartNotFound", "Chart was not found in the repo :mag:"
+	case pkg.ErrorRepoServerError:
+		return "RepoUnavailable", "Chart repo is unavailable :fire:"
+	case pkg.ErrorRepoTimeout:
+		return "RepoTimeout", "Chart repo timed out :hourglass:"
+	case pkg.ErrorRepoUnexpectedStatus:
+		return "RepoUnexpectedStatus", "Chart repo returned an unexpected response :question:"
+	case pkg.ErrorChartEntryNotFoundInRepoIndex:
+		return "ChartNotInIndex", "Chart version is not in the repo index :mag:"
+	case pkg.ErrorChartDigestMismatch:
+		return "ChartDigestMismatch", "Chart does not match the digest in the repo index :warning:"
+	case pkg.ErrorChartVerificationFailed:
+		return "ChartVerificationFailed", "Chart failed provenance verification :lock:"
+	case pkg.ErrorInvalidChartReference:
+		return "InvalidChartReference", "Chart name or version is not valid :no_entry:"
 	}
+	return "", ""
+}
 
-	// return chart path
-	return chartPath, nil
+// chartPullFailed records why a chart could not be pulled and notifies about it
+func (r *ReleaseReconciler) chartPullFailed(cr *v1alpha1.Release, reason, msg string, errPullingChart error) (ctrl.Result, error) {
+	r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+		Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+		Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+		EventType: cNotifyLib.Failure,
+		Fields: map[string]string{
+			"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+			"Namespace": cr.GetNamespace(),
+			"Reason":    fmt.Sprintf("%v %v", msg, errPullingChart)},
+	})
+	markFailed(cr, reason, errPullingChart)
+	return r.retryLater(cr, errPullingChart)
 }
 
 func isReleasePending(releaseInfo *release.Release) bool {
@@ -96,32 +180,87 @@ func isReleasePending(releaseInfo *release.Release) bool {
 func getReleaseInstallOptions(cr *v1alpha1.Release) v3.InstallOptions {
 	spec := cr.Spec
 	installOptions := v3.InstallOptions{
-		Namespace:                cr.GetNamespace(),
+		Namespace:                releaseNamespace(cr),
 		DryRun:                   spec.DryRun,
-		Wait:                     spec.Wait,
+		Wait:                     spec.Wait && !pollReadiness(cr),
 		Timeout:                  time.Duration(spec.WaitTimeout),
-		ReleaseName:              cr.GetName(),
+		ReleaseName:              helmReleaseName(cr),
 		DisableHooks:             spec.DisableHooks,
 		DisableOpenAPIValidation: spec.DisableOpenAPIValidation,
 		Atomic:                   spec.Atomic,
 		IncludeCRDs:              spec.IncludeCRDs,
+		PostRenderer:             getPostRenderer(cr),
 	}
 	return installOptions
 }
 
 func getReleaseUpgradeOptions(cr *v1alpha1.Release) v3.UpgradeOptions {
 	upgradeOpts := v3.UpgradeOptions{
-		Namespace:                cr.GetNamespace(),
+		Namespace:                releaseNamespace(cr),
 		DryRun:                   cr.Spec.DryRun,
-		Wait:                     cr.Spec.Wait,
-		Timeout:                  time.Duration(cr.Spec.WaitTimeout),
-		ReleaseName:              cr.GetName(),
+		Wait:                     cr.Spec.Wait && !pollReadiness(cr),
+		Timeout:                  time.Duration(cr.Spec.WaitTimeout) * time.Second,
+		ReleaseName:              helmReleaseName(cr),
 		DisableHooks:             cr.Spec.DisableHooks,
 		DisableOpenAPIValidation: cr.Spec.DisableOpenAPIValidation,
 		Atomic:                   cr.Spec.Atomic,
 		CleanupOnFail:            cr.Spec.CleanupOnFail,
 		SkipCRDs:                 !cr.Spec.IncludeCRDs,
 		Force:                    cr.Spec.ForceUpgrade,
+		PostRenderer:             getPostRenderer(cr),
+	}
+	// a failed upgrade can only be remediated when helm waits long enough to see it fail
+	if remediationEnabled(cr) {
+		upgradeOpts.Wait = true
+		if upgradeOpts.Timeout == 0 {
+			upgradeOpts.Timeout = defaultRemediationWaitTimeout
+		}
 	}
 	return upgradeOpts
 }
+
+// getPostRenderer converts the post renderers of the Release for helm, nil when there are none
+func getPostRenderer(cr *v1alpha1.Release) postrender.PostRenderer {
+	if len(cr.Spec.PostRenderers) == 0 {
+		return nil
+	}
+	postRenderers := make(v3.PostRenderers, 0, len(cr.Spec.PostRenderers))
+	for _, spec := range cr.Spec.PostRenderers {
+		postRenderer := v3.PostRenderer{Labels: spec.Labels, Annotations: spec.Annotations}
+		if spec.Kustomize != nil {
+			for _, patch := range spec.Kustomize.Patches {
+				converted := v3.Patch{Patch: patch.Patch}
+				if target := patch.Target; target != nil {
+					converted.Target = &v3.PatchTarget{Group: target.Group, Version: target.Version, Kind: target.Kind,
+						Name: target.Name, Namespace: target.Namespace, LabelSelector: target.LabelSelector}
+				}
+				postRenderer.Patches = append(postRenderer.Patches, converted)
+			}
+		}
+		postRenderers = append(postRenderers, postRenderer)
+	}
+	return postRenderers
+}
+
+// rollback rolls the helm release back to a revision and returns the revision helm created for it.
+// Objects with immutable fields cannot be patched back, those rollbacks are retried replacing the objects instead.
+func (r *ReleaseReconciler) rollback(cr *v1alpha1.Release, actionConfig *v3.HelmV3, toRevision int) (*release.Release, error) {
+	rollbackOpts := v3.RollbackToRevisionOptions{
+		Wait:        cr.Spec.Wait,
+		WaitTimeout: cr.Spec.WaitTimeout,
+		ToRevision:  toRevision,
+	}
+	errRollingBack := actionConfig.RollbackToRevision(helmReleaseName(cr), rollbackOpts)
+	if v3.IsImmutableFieldError(errRollingBack) {
+		r.Log.Info(fmt.Sprintf("%v/%v rollback hit an immutable field, retrying with a forced replace: %v", cr.GetNamespace(), cr.GetName(), errRollingBack))
+		rollbackOpts.Force = true
+		errRollingBack = actionConfig.RollbackToRevision(helmReleaseName(cr), rollbackOpts)
+	}
+
+	// helm records a revision for failed rollbacks too
+	releaseInfo, errGettingReleaseInfo := actionConfig.GetRelease(helmReleaseName(cr))
+	if errRollingBack != nil {
+		return releaseInfo, errRollingBack
+	}
+	return releaseInfo, errGettingReleaseInfo
+}
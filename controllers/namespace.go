@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+)
+
+// releaseNamespace is the namespace the helm release is installed in. Once installed that is the recorded namespace,
+// so a changed spec.targetNamespace never leaves the installed release behind.
+func releaseNamespace(cr *v1alpha1.Release) string {
+	if cr.Status.HelmReleaseNamespace != "" {
+		return cr.Status.HelmReleaseNamespace
+	}
+	return specReleaseNamespace(cr)
+}
+
+// helmReleaseName is the name of the helm release, the recorded name once installed
+func helmReleaseName(cr *v1alpha1.Release) string {
+	if cr.Status.HelmReleaseName != "" {
+		return cr.Status.HelmReleaseName
+	}
+	return specReleaseName(cr)
+}
+
+func specReleaseNamespace(cr *v1alpha1.Release) string {
+	if cr.Spec.TargetNamespace != "" {
+		return cr.Spec.TargetNamespace
+	}
+	return cr.GetNamespace()
+}
+
+func specReleaseName(cr *v1alpha1.Release) string {
+	if cr.Spec.ReleaseName != "" {
+		return cr.Spec.ReleaseName
+	}
+	return cr.GetName()
+}
+
+// recordReleaseLocation records where the helm release lives, from then on genoa only manages the release there
+func recordReleaseLocation(cr *v1alpha1.Release) {
+	cr.Status.HelmReleaseName = helmReleaseName(cr)
+	cr.Status.HelmReleaseNamespace = releaseNamespace(cr)
+}
+
+// releaseMoved is true when the spec points at a different helm release than the one installed
+func releaseMoved(cr *v1alpha1.Release) bool {
+	return cr.Status.HelmReleaseNamespace != "" &&
+		(specReleaseNamespace(cr) != cr.Status.HelmReleaseNamespace || specReleaseName(cr) != cr.Status.HelmReleaseName)
+}
+
+// checkReleaseTarget refuses helm releases the Release may not manage: a moved release, a namespace that does not
+// allow Releases from the namespace of this one, and helm releases another Release already manages
+func (r *ReleaseReconciler) checkReleaseTarget(cr *v1alpha1.Release) (bool, string, string, error) {
+	if releaseMoved(cr) {
+		return true, "ReleaseMoved", fmt.Sprintf("helm release %v/%v is installed, genoa does not move it to %v/%v; "+
+			"restore targetNamespace and releaseName, or delete and recreate the Release",
+			cr.Status.HelmReleaseNamespace, cr.Status.HelmReleaseName, specReleaseNamespace(cr), specReleaseName(cr)), nil
+	}
+
+	allowed, errCheckingNamespace := r.targetNamespaceAllowed(cr)
+	if errCheckingNamespace != nil {
+		return false, "", "", errCheckingNamespace
+	}
+	if !allowed {
+		return true, "TargetNamespaceNotAllowed", fmt.Sprintf("namespace %v does not allow Releases from %v, "+
+			"add %v to its %v annotation", releaseNamespace(cr), cr.GetNamespace(), cr.GetNamespace(), utils.AllowedReleaseNamespaces), nil
+	}
+
+	owner, errFindingOwner := r.helmReleaseOwner(cr)
+	if errFindingOwner != nil {
+		return false, "", "", errFindingOwner
+	}
+	if owner != nil {
+		return true, "ReleaseConflict", fmt.Sprintf("helm release %v/%v is managed by Release %v/%v",
+			releaseNamespace(cr), helmReleaseName(cr), owner.GetNamespace(), owner.GetName()), nil
+	}
+	return false, "", "", nil
+}
+
+// targetNamespaceAllowed is true for the namespace of the Release itself, for Releases in a trusted namespace and for
+// namespaces listing the namespace of the Release in their allowed-release-namespaces annotation
+func (r *ReleaseReconciler) targetNamespaceAllowed(cr *v1alpha1.Release) (bool, error) {
+	return r.namespaceAllows(releaseNamespace(cr), cr.GetNamespace())
+}
+
+// namespaceAllows is true when Releases in the from namespace may act on the target namespace: it is the same namespace,
+// from is trusted or the target lists from in its allowed-release-namespaces annotation
+func (r *ReleaseReconciler) namespaceAllows(target, from string) (bool, error) {
+	if target == from {
+		return true, nil
+	}
+	for _, trusted := range r.TrustedNamespaces {
+		if trusted == from {
+			return true, nil
+		}
+	}
+	namespace := &v1.Namespace{}
+	if errGettingNamespace := r.Client.Get(context.TODO(), types.NamespacedName{Name: target}, namespace); errGettingNamespace != nil {
+		if apiErrors.IsNotFound(errGettingNamespace) {
+			return false, nil
+		}
+		return false, errGettingNamespace
+	}
+	for _, allowed := range strings.Split(namespace.GetAnnotations()[utils.AllowedReleaseNamespaces], ",") {
+		if strings.TrimSpace(allowed) == from {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// helmReleaseOwner returns the other Release that recorded the helm release of this one, nil when there is none
+func (r *ReleaseReconciler) helmReleaseOwner(cr *v1alpha1.Release) (*v1alpha1.Release, error) {
+	releases := &v1alpha1.ReleaseList{}
+	if errListing := r.Client.List(context.TODO(), releases); errListing != nil {
+		return nil, errListing
+	}
+	for i := range releases.Items {
+		other := &releases.Items[i]
+		if other.GetUID() != cr.GetUID() &&
+			other.Status.HelmReleaseNamespace == releaseNamespace(cr) && other.Status.HelmReleaseName == helmReleaseName(cr) {
+			return other, nil
+		}
+	}
+	return nil, nil
+}
+
+// managesHelmRelease is true when deleting the Release may uninstall its helm release: genoa recorded it for this Release,
+// or it is the helm release named after the Release in its own namespace, as installed before the location was recorded
+func managesHelmRelease(cr *v1alpha1.Release) bool {
+	return cr.Status.HelmReleaseNamespace != "" || (cr.Spec.TargetNamespace == "" && cr.Spec.ReleaseName == "")
+}
+
+// markTargetRefused holds a Release that may not manage the helm release its spec points at
+func markTargetRefused(cr *v1alpha1.Release, reason, message string) {
+	generation := cr.GetGeneration()
+	cr.Status.ObservedGeneration = generation
+	cr.Status.LastError = message
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reason, message, generation)
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionFalse, reason, "", generation)
+	cr.Status.SetCondition(v1alpha1.ConditionStalled, metav1.ConditionTrue, reason, message, generation)
+}
+
+// ensureNamespace creates the namespace of the helm release when the Release asks for it and labels it as owned by genoa.
+// Namespaces that already exist are never labeled, genoa only deletes the ones it created or a human labeled as owned.
+// The namespace metadata of the spec is kept up to date on owned namespaces.
+func (r *ReleaseReconciler) ensureNamespace(cr *v1alpha1.Release) error {
+	name := releaseNamespace(cr)
+	namespace := &v1.Namespace{}
+	errGettingNamespace := r.Client.Get(context.TODO(), types.NamespacedName{Name: name}, namespace)
+	if apiErrors.IsNotFound(errGettingNamespace) {
+		if !cr.Spec.CreateNamespace {
+			return nil
+		}
+		namespace = &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+		if metadata := cr.Spec.NamespaceMetadata; metadata != nil {
+			for key, value := range metadata.Labels {
+				namespace.Labels[key] = value
+			}
+			namespace.Annotations = metadata.Annotations
+		}
+		namespace.Labels[utils.NamespaceOwnedLabel] = "true"
+		r.Log.Info(fmt.Sprintf("%v/%v creating namespace %v", cr.GetNamespace(), cr.GetName(), name))
+		if errCreating := r.Client.Create(context.TODO(), namespace); errCreating != nil && !apiErrors.IsAlreadyExists(errCreating) {
+			return errCreating
+		}
+		return nil
+	}
+	if errGettingNamespace != nil {
+		return errGettingNamespace
+	}
+
+	metadata := cr.Spec.NamespaceMetadata
+	if !cr.Spec.CreateNamespace || metadata == nil || namespace.GetLabels()[utils.NamespaceOwnedLabel] != "true" {
+		return nil
+	}
+	changed := false
+	if namespace.Labels == nil {
+		namespace.Labels = map[string]string{}
+	}
+	for key, value := range metadata.Labels {
+		if current, ok := namespace.Labels[key]; !ok || current != value {
+			namespace.Labels[key] = value
+			changed = true
+		}
+	}
+	if namespace.Annotations == nil {
+		namespace.Annotations = map[string]string{}
+	}
+	for key, value := range metadata.Annotations {
+		if current, ok := namespace.Annotations[key]; !ok || current != value {
+			namespace.Annotations[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	r.Log.Info(fmt.Sprintf("%v/%v updating the metadata of namespace %v", cr.GetNamespace(), cr.GetName(), name))
+	return r.Client.Update(context.TODO(), namespace)
+}
+
+// deleteNamespaceIfUnused deletes the namespace of a deleted Release when genoa owns it and no other Release installs into it,
+// wherever those Releases live. Releases that are being deleted too do not count, so a group of Releases deleted together still takes the namespace along.
+func (r *ReleaseReconciler) deleteNamespaceIfUnused(cr *v1alpha1.Release) error {
+	name := releaseNamespace(cr)
+	namespace := &v1.Namespace{}
+	if errGettingNamespace := r.Client.Get(context.TODO(), types.NamespacedName{Name: name}, namespace); errGettingNamespace != nil {
+		if apiErrors.IsNotFound(errGettingNamespace) {
+			return nil
+		}
+		return errGettingNamespace
+	}
+	if namespace.GetLabels()[utils.NamespaceOwnedLabel] != "true" || namespace.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	releases := &v1alpha1.ReleaseList{}
+	if errListing := r.Client.List(context.TODO(), releases); errListing != nil {
+		return errListing
+	}
+	for i := range releases.Items {
+		other := &releases.Items[i]
+		if other.GetUID() != cr.GetUID() && other.GetDeletionTimestamp() == nil && releaseNamespace(other) == name {
+			r.Log.Info(fmt.Sprintf("%v/%v keeping namespace %v, %v/%v still uses it", cr.GetNamespace(), cr.GetName(), name, other.GetNamespace(), other.GetName()))
+			return nil
+		}
+	}
+
+	r.Log.Info(fmt.Sprintf("%v/%v deleting namespace %v, no Releases are left in it", cr.GetNamespace(), cr.GetName(), name))
+	if errDeleting := r.Client.Delete(context.TODO(), namespace); errDeleting != nil && !apiErrors.IsNotFound(errDeleting) {
+		return errDeleting
+	}
+	return nil
+}
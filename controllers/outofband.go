@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const reasonOutOfBandRevision = "OutOfBandRevision"
+
+// checkOutOfBand looks for helm revisions genoa did not create, e.g. a manual helm upgrade or helm rollback,
+// and handles them according to spec.outOfBandPolicy.
+// It returns the release to keep reconciling against and whether an out of band revision is held, which is not
+// changed until the spec generation does.
+func (r *ReleaseReconciler) checkOutOfBand(cr *v1alpha1.Release, actionConfig *v3.HelmV3, releaseInfo *release.Release) (*release.Release, bool, error) {
+	generation := cr.GetGeneration()
+	if cr.Status.LastProducedRevision == 0 {
+		// released by an older genoa, take the current revision as ours
+		cr.Status.LastProducedRevision = releaseInfo.Version
+	}
+	if releaseInfo.Version <= cr.Status.LastProducedRevision {
+		if condition := cr.Status.GetCondition(v1alpha1.ConditionDrifted); condition != nil && condition.Reason == reasonOutOfBandRevision {
+			cr.Status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionFalse, "NoOutOfBandRevision", "", generation)
+		}
+		// an adopted revision is genoa's own, but still held until the spec changes
+		return releaseInfo, holdOutOfBand(cr, releaseInfo, false), nil
+	}
+
+	message := fmt.Sprintf("helm revision %v was not created by genoa, the last one it created is %v", releaseInfo.Version, cr.Status.LastProducedRevision)
+	r.Log.Info(fmt.Sprintf("%v/%v %v", cr.GetNamespace(), cr.GetName(), message))
+
+	switch cr.Spec.OutOfBandPolicy {
+	case v1alpha1.OutOfBandAdopt:
+		recordHistory(cr, releaseInfo, v1alpha1.HistoryOutcomeAdopted, message)
+		r.notifyOutOfBand(cr, cNotifyLib.Success, fmt.Sprintf("Release adopted an out of band change :handshake: %v", message))
+		return releaseInfo, holdOutOfBand(cr, releaseInfo, true), nil
+
+	case v1alpha1.OutOfBandRevert:
+		revertTo := cr.Status.LastProducedRevision
+		revertedRelease, errReverting := r.rollback(cr, actionConfig, revertTo)
+		if errReverting != nil {
+			r.notifyOutOfBand(cr, cNotifyLib.Failure, fmt.Sprintf("Release failed to revert an out of band change :bug: %v: %v", message, errReverting))
+			markFailed(cr, "RevertFailed", errReverting)
+			if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+				return nil, false, err
+			}
+			return nil, false, errReverting
+		}
+		recordHistory(cr, revertedRelease, v1alpha1.HistoryOutcomeRolledBack, fmt.Sprintf("reverted out of band revision %v", releaseInfo.Version))
+		r.notifyOutOfBand(cr, cNotifyLib.Success, fmt.Sprintf("Release reverted an out of band change :rewind: %v", message))
+		cr.Status.OutOfBandHold = nil
+		return revertedRelease, false, nil
+	}
+
+	if !cr.Status.IsConditionTrue(v1alpha1.ConditionDrifted) {
+		r.notifyOutOfBand(cr, cNotifyLib.Warning, fmt.Sprintf("Release was changed outside of genoa :warning: %v", message))
+	}
+	cr.Status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionTrue, reasonOutOfBandRevision, message, generation)
+	return releaseInfo, holdOutOfBand(cr, releaseInfo, true), nil
+}
+
+// holdOutOfBand reports whether the revision is an out of band revision to leave alone. A newly found revision is held
+// at the current generation, found is false when only a revision held before may still be in place. Once the spec
+// generation moves on the hold is released and the spec is applied over the revision.
+func holdOutOfBand(cr *v1alpha1.Release, releaseInfo *release.Release, found bool) bool {
+	hold := cr.Status.OutOfBandHold
+	if found && (hold == nil || hold.Revision != releaseInfo.Version) {
+		hold = &v1alpha1.OutOfBandHold{Revision: releaseInfo.Version, Generation: cr.GetGeneration()}
+		cr.Status.OutOfBandHold = hold
+	}
+	if hold == nil {
+		return false
+	}
+	if hold.Revision != releaseInfo.Version || hold.Generation != cr.GetGeneration() {
+		cr.Status.OutOfBandHold = nil
+		return false
+	}
+	return true
+}
+
+// markOutOfBandHeld reports an out of band revision that is left in place until the spec changes
+func markOutOfBandHeld(cr *v1alpha1.Release, releaseInfo *release.Release) {
+	message := fmt.Sprintf("helm revision %v was made outside of genoa and is kept until the Release spec changes", releaseInfo.Version)
+	if cr.Spec.OutOfBandPolicy == v1alpha1.OutOfBandAdopt {
+		markReady(cr, releaseInfo, "OutOfBandAdopted", message)
+		return
+	}
+	generation := cr.GetGeneration()
+	cr.Status.ObservedGeneration = generation
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reasonOutOfBandRevision, message, generation)
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionFalse, reasonOutOfBandRevision, "", generation)
+}
+
+// recordLatestRevision takes the latest helm revision as genoa's own after a failed helm action. Helm can create more
+// revisions than the one that failed, e.g. the rollback of an atomic upgrade, and those are not out of band.
+func recordLatestRevision(cr *v1alpha1.Release, actionConfig *v3.HelmV3) {
+	if latest, errGettingRelease := actionConfig.GetRelease(helmReleaseName(cr)); errGettingRelease == nil && latest.Version > cr.Status.LastProducedRevision {
+		cr.Status.LastProducedRevision = latest.Version
+	}
+}
+
+func (r *ReleaseReconciler) notifyOutOfBand(cr *v1alpha1.Release, eventType cNotifyLib.NotifyEventType, reason string) {
+	r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+		Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+		Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+		EventType: eventType,
+		Fields: map[string]string{
+			"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+			"Namespace": cr.GetNamespace(),
+			"Reason":    reason},
+	})
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultReadinessTimeout matches the helm default for --wait
+	defaultReadinessTimeout = 5 * time.Minute
+	readinessPollInterval   = 10 * time.Second
+	// maxUnreadyInStatus keeps the status readable for releases with many resources
+	maxUnreadyInStatus = 10
+)
+
+// pollReadiness is true when the Release waits for its resources on later reconciles instead of blocking a worker in helm.
+// Remediation needs helm to see the upgrade fail, so it always blocks.
+func pollReadiness(cr *v1alpha1.Release) bool {
+	return cr.Spec.Wait && cr.Spec.WaitMode == v1alpha1.WaitModePoll && !remediationEnabled(cr) && !cr.Spec.DryRun
+}
+
+// awaitReadiness starts waiting on the resources of a revision helm installed without waiting
+func awaitReadiness(cr *v1alpha1.Release, releaseInfo *release.Release) {
+	cr.Status.Readiness = &v1alpha1.ReadinessStatus{Revision: releaseInfo.Version, Since: metav1.Now()}
+	markReconciling(cr, "WaitingForResources", fmt.Sprintf("waiting for the resources of revision %v", releaseInfo.Version))
+}
+
+// readinessResult is what checkReadiness found out about the resources of a revision
+type readinessResult struct {
+	// ready is true once every resource is ready
+	ready bool
+	// errNotReady is set when a resource failed or waitTimeout passed, which counts as a failed rollout
+	errNotReady error
+	// failed is set when a resource will not become ready without a change, waiting again would not help
+	failed bool
+}
+
+// checkReadiness looks at the resources of the revision being waited on without blocking. A rollout that timed out
+// starts a new waitTimeout, so the retry after the backoff waits again instead of failing straight away. Resources that
+// failed for good, e.g. a failed Job or a Deployment past its progress deadline, fail the rollout without any retries.
+func (r *ReleaseReconciler) checkReadiness(cr *v1alpha1.Release, actionConfig *v3.HelmV3, releaseInfo *release.Release) (readinessResult, error) {
+	total, unready, errCheckingReadiness := actionConfig.CheckReadiness(releaseInfo)
+	if errCheckingReadiness != nil {
+		return readinessResult{}, errCheckingReadiness
+	}
+	if len(unready) == 0 {
+		r.Log.Info(fmt.Sprintf("%v/%v all %v resources of revision %v are ready", cr.GetNamespace(), cr.GetName(), total, releaseInfo.Version))
+		cr.Status.Readiness = nil
+		return readinessResult{ready: true}, nil
+	}
+
+	readiness := cr.Status.Readiness
+	readiness.Total = total
+	readiness.Ready = total - len(unready)
+	readiness.Unready = nil
+	var failed []string
+	for i, unreadyResource := range unready {
+		if i < maxUnreadyInStatus {
+			readiness.Unready = append(readiness.Unready, unreadyResource.String())
+		}
+		if unreadyResource.Failed {
+			failed = append(failed, unreadyResource.String())
+		}
+	}
+
+	timeout := time.Duration(cr.Spec.WaitTimeout) * time.Second
+	if timeout == 0 {
+		timeout = defaultReadinessTimeout
+	}
+	var errNotReady error
+	if len(failed) > 0 {
+		errNotReady = fmt.Errorf("resources failed: %v", strings.Join(failed, ", "))
+	} else if time.Since(readiness.Since.Time) > timeout {
+		errNotReady = fmt.Errorf("resources not ready after %v: %v", timeout, strings.Join(readiness.Unready, ", "))
+		readiness.Since = metav1.Now()
+	}
+	if errNotReady != nil {
+		r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+			Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+			Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+			EventType: cNotifyLib.Failure,
+			Fields: map[string]string{
+				"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+				"Namespace": cr.GetNamespace(),
+				"Reason":    fmt.Sprintf("Release did not become ready :hourglass: :bug: %v", errNotReady)},
+		})
+		return readinessResult{errNotReady: errNotReady, failed: len(failed) > 0}, nil
+	}
+
+	message := fmt.Sprintf("%v of %v resources ready, waiting for %v", readiness.Ready, total, strings.Join(readiness.Unready, ", "))
+	r.Log.Info(fmt.Sprintf("%v/%v %v", cr.GetNamespace(), cr.GetName(), message))
+	markReconciling(cr, "WaitingForResources", message)
+	return readinessResult{}, nil
+}
+
+// markResourcesFailed stalls a Release whose resources failed for good instead of spending its retries waiting on them
+func markResourcesFailed(cr *v1alpha1.Release, err error) {
+	generation := cr.GetGeneration()
+	cr.Status.FailureCount = maxRetries(cr) + 1
+	cr.Status.SetCondition(v1alpha1.ConditionStalled, metav1.ConditionTrue, "ResourcesFailed",
+		fmt.Sprintf("%v, change the Release or set the retry-now annotation to check again", err), generation)
+}
@@ -28,15 +28,16 @@ import (
 	"helm.sh/helm/v3/pkg/storage/driver"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
-	"os"
 	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"strings"
 	"time"
 
@@ -46,26 +47,62 @@ import (
 // ReleaseReconciler reconciles a Release object
 type ReleaseReconciler struct {
 	client.Client
-	Log      logr.Logger
-	Scheme   *runtime.Scheme
-	Cfg      *rest.Config
-	Notifier cNotifyLib.Notify
+	Log         logr.Logger
+	Scheme      *runtime.Scheme
+	Cfg         *rest.Config
+	Notifier    cNotifyLib.Notify
+	ChartCache  *v3.ChartCache
+	ChartMirror *v3.ChartMirror
+	RepoOptions map[string]v3.RepoOptions
+	// PrometheusAddress is the Prometheus canaries are analysed with unless the Release names its own
+	PrometheusAddress string
+	// ClusterName and ClusterDomain are available to values templates
+	ClusterName   string
+	ClusterDomain string
+	// TrustedNamespaces are the namespaces whose Releases may install into any namespace, Releases anywhere else
+	// only install into their own namespace or into namespaces that allow theirs with an annotation
+	TrustedNamespaces []string
 }
 
 func (r *ReleaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &coverosv1alpha1.Release{}, dependsOnIndexField, indexDependencies); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		WithOptions(controller.Options{MaxConcurrentReconciles: 7}).
-		For(&coverosv1alpha1.Release{}).
-		WithEventFilter(predicate.Funcs{
+		For(&coverosv1alpha1.Release{}, builder.WithPredicates(predicate.Funcs{
 			UpdateFunc: func(e event.UpdateEvent) bool {
-				return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration()
+				// spec changes, plus the periodic resyncs which do not change the resource version
+				return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+					e.MetaOld.GetResourceVersion() == e.MetaNew.GetResourceVersion() ||
+					suspendAnnotationChanged(e.MetaOld, e.MetaNew) ||
+					e.MetaOld.GetAnnotations()[utils.RetryNowAnnotation] != e.MetaNew.GetAnnotations()[utils.RetryNowAnnotation] ||
+					e.MetaOld.GetAnnotations()[utils.ConfirmAdoptionAnnotation] != e.MetaNew.GetAnnotations()[utils.ConfirmAdoptionAnnotation]
 			},
-		}).
+		})).
+		Watches(&source.Kind{Type: &coverosv1alpha1.Release{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.dependentsOf)},
+			builder.WithPredicates(predicate.Funcs{UpdateFunc: dependencyChanged})).
+		Watches(&source.Kind{Type: &coverosv1alpha1.Release{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(dependenciesOf)},
+			builder.WithPredicates(predicate.Funcs{
+				CreateFunc: func(event.CreateEvent) bool { return false },
+				UpdateFunc: func(event.UpdateEvent) bool { return false },
+			})).
+		Watches(&source.Kind{Type: &coverosv1alpha1.ReleaseApproval{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(approvedRelease)}).
+		Watches(&source.Kind{Type: &coverosv1alpha1.DeploymentSchedule{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.releasesUsingSchedule)}).
 		Complete(r)
 }
 
 // +kubebuilder:rbac:groups=coveros.apps.com,resources=Releases,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=coveros.apps.com,resources=Releases/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=coveros.apps.com,resources=DeploymentSchedules,verbs=get;list;watch
+// +kubebuilder:rbac:groups=coveros.apps.com,resources=ReleaseApprovals,verbs=get;list;watch
 func (r *ReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	_ = context.Background()
 	_ = r.Log.WithValues("Release", req.NamespacedName)
@@ -81,16 +118,15 @@ func (r *ReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, err
 	}
+	originalStatus := cr.Status.DeepCopy()
 	notificationChannel := utils.GetChannelIDForNotification(cr.ObjectMeta)
-	hrName := cr.GetName()
-	hrNamespace := cr.GetNamespace()
 	repoWithChartName := strings.SplitN(cr.Spec.Chart, "/", 2)
 	var justChartName = repoWithChartName[1]
 	if strings.Contains(justChartName, "/") {
 		justChartName = strings.Split(justChartName, "/")[1]
 	}
 	repoAlias, chartName := repoWithChartName[0], repoWithChartName[1]
-	helmV3, errCreatingActionConfig := v3.NewActionConfig(cr.GetNamespace(), r.Cfg)
+	helmV3, errCreatingActionConfig := v3.NewActionConfig(releaseNamespace(cr), r.Cfg)
 	if errCreatingActionConfig != nil {
 		return ctrl.Result{}, errCreatingActionConfig
 	}
@@ -102,6 +138,18 @@ func (r *ReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 
 	// handle delete
 	if cr.GetDeletionTimestamp() != nil {
+		// dependents go first
+		deletionBlocked, errCheckingDependents := r.blockDeletion(cr)
+		if errCheckingDependents != nil {
+			return ctrl.Result{}, errCheckingDependents
+		}
+		if deletionBlocked {
+			// requeued once a dependent is gone
+			if !reflect.DeepEqual(originalStatus, &cr.Status) {
+				return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+			}
+			return ctrl.Result{}, nil
+		}
 		if errCleaningUp := r.cleanup(cr, helmV3); errCleaningUp != nil {
 			return ctrl.Result{}, errCleaningUp
 		}
@@ -117,50 +165,129 @@ func (r *ReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, nil // do not requeue
 	}
 
-	if cr.Spec.DependsOn.GetName() != "" {
-		name := cr.Spec.DependsOn.GetName()
-		ns := cr.Spec.DependsOn.GetNamespace()
-		if ns == "" {
-			ns = "default"
+	// a new generation gets a fresh retry budget
+	if cr.Status.FailureCount > 0 && cr.Status.ObservedGeneration != cr.GetGeneration() {
+		resetRetries(cr, "NewGeneration")
+	}
+	retryNow := retryNowRequested(cr)
+	if retryNow {
+		r.Log.Info(fmt.Sprintf("%v retry requested with the %v annotation", req.NamespacedName, utils.RetryNowAnnotation))
+		cr.Status.LastRetryNow = cr.GetAnnotations()[utils.RetryNowAnnotation]
+	}
+
+	suspended := isSuspended(cr)
+	r.markSuspended(cr, suspended)
+	if suspended {
+		r.Log.Info(fmt.Sprintf("%v is suspended, skipping", req.NamespacedName))
+		if !reflect.DeepEqual(originalStatus, &cr.Status) {
+			return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
 		}
+		return ctrl.Result{}, nil
+	}
 
-		dependsOnCr := &coverosv1alpha1.Release{}
-		if errGettingDependsOnCr := r.Client.Get(context.TODO(),
-			types.NamespacedName{Name: name, Namespace: ns}, dependsOnCr); errGettingDependsOnCr != nil {
-			return ctrl.Result{}, errGettingDependsOnCr
+	refused, reason, message, errCheckingTarget := r.checkReleaseTarget(cr)
+	if errCheckingTarget != nil {
+		return ctrl.Result{}, errCheckingTarget
+	}
+	if refused {
+		r.Log.Info(fmt.Sprintf("%v %v", req.NamespacedName, message))
+		markTargetRefused(cr, reason, message)
+		if !reflect.DeepEqual(originalStatus, &cr.Status) {
+			return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
 		}
-		// wait until the parent release is installed
-		if !dependsOnCr.Status.Installed {
-			r.Log.Info(fmt.Sprintf("%v depends on %v/%v and is not ready yet.. will re-check back in a few...", req.NamespacedName, ns, name))
-			return ctrl.Result{Requeue: true, RequeueAfter: 60 * time.Second}, nil
+		return ctrl.Result{}, nil
+	}
+
+	if len(dependencyKeys(cr)) > 0 {
+		dependenciesReady, reason, message, errCheckingDependencies := r.checkDependencies(cr)
+		if errCheckingDependencies != nil {
+			return ctrl.Result{}, errCheckingDependencies
 		}
+		if !dependenciesReady {
+			// dependents are requeued when a dependency becomes ready, no need to poll
+			r.Log.Info(fmt.Sprintf("%v dependencies are not ready yet: %v", req.NamespacedName, message))
+			markDependencies(cr, false, reason, message)
+			if !reflect.DeepEqual(originalStatus, &cr.Status) {
+				return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+			}
+			return ctrl.Result{}, nil
+		}
+		markDependencies(cr, true, reason, "")
 	}
 
-	if cr.Status.FailureCount > cr.Spec.MaxRetries {
-		r.Log.Info(fmt.Sprintf("%v has reached max reconcile limit, please update spec.maxRetries if you want to retry", req.NamespacedName))
+	if !retryNow && retryBudgetExhausted(cr) {
+		r.Log.Info(fmt.Sprintf("%v has reached max reconcile limit, change the release or set the %v annotation to retry", req.NamespacedName, utils.RetryNowAnnotation))
+		if !reflect.DeepEqual(originalStatus, &cr.Status) {
+			return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+		}
 		return ctrl.Result{}, nil
 	}
+	if !retryNow && cr.Status.NextRetryTime != nil && time.Now().Before(cr.Status.NextRetryTime.Time) {
+		// resyncs do not get to skip the backoff
+		retryAfter := time.Until(cr.Status.NextRetryTime.Time)
+		r.Log.Info(fmt.Sprintf("%v backing off for %v after %v failures", req.NamespacedName, retryAfter.Round(time.Second), cr.Status.FailureCount))
+		if !reflect.DeepEqual(originalStatus, &cr.Status) {
+			return ctrl.Result{RequeueAfter: retryAfter}, utils.UpdateCrStatus(cr, r.Client)
+		}
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	values, errRenderingValues := r.renderValues(cr)
+	if errRenderingValues != nil {
+		markFailed(cr, "ValuesTemplateFailed", errRenderingValues)
+		return r.retryLater(cr, errRenderingValues)
+	}
 
-	releaseInfo, errGettingReleaseInfo := helmV3.GetRelease(hrName)
+	releaseInfo, errGettingReleaseInfo := helmV3.GetRelease(helmReleaseName(cr))
+	if cr.Spec.DryRun && (errGettingReleaseInfo == nil || errors.Is(errGettingReleaseInfo, driver.ErrReleaseNotFound)) {
+		return r.dryRun(cr, helmV3, releaseInfo, repoAlias, chartName, values, originalStatus)
+	}
+	// the preview is stale once the change is applied for real
+	cr.Status.DryRun = nil
+	// on every reconcile, not just installs, so a deleted namespace comes back and releases in sync keep their namespace metadata
+	if errEnsuringNamespace := r.ensureNamespace(cr); errEnsuringNamespace != nil {
+		return ctrl.Result{}, errEnsuringNamespace
+	}
 	if errGettingReleaseInfo != nil {
 		if errors.Is(errGettingReleaseInfo, driver.ErrReleaseNotFound) {
 			r.Log.Info("release not found, installing now...")
 
-			chartPath, errPullingChart := r.pullChart(hrNamespace, hrName, repoAlias, chartName, cr.Spec.Version, helmV3)
+			changeAllowed, retryAfter, errCheckingSchedule := r.checkSchedule(cr, nil, values)
+			if errCheckingSchedule != nil {
+				return ctrl.Result{}, errCheckingSchedule
+			}
+			if !changeAllowed {
+				if !reflect.DeepEqual(originalStatus, &cr.Status) {
+					return ctrl.Result{RequeueAfter: retryAfter}, utils.UpdateCrStatus(cr, r.Client)
+				}
+				return ctrl.Result{RequeueAfter: retryAfter}, nil
+			}
+
+			chartPath, releaseChart, errPullingChart := r.pullChart(cr, repoAlias, chartName, cr.Spec.Version, helmV3)
 			if errPullingChart != nil {
 				if _, ok := errPullingChart.(pkg.ErrorHelmRepoNeedsRefresh); ok {
-					cr.Status.FailureCount++
+					markFailed(cr, "RepoIndexRefresh", errPullingChart)
 					if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
 						return ctrl.Result{}, err
 					}
-					return ctrl.Result{Requeue: true}, helmV3.RefreshRepoIndex(repoAlias)
+					return ctrl.Result{Requeue: true}, helmV3.RefreshRepoIndex(repoAlias, r.getRepoOptions(repoAlias))
+				}
+				if reason, msg := pullChartFailureReason(errPullingChart); reason != "" {
+					return r.chartPullFailed(cr, reason, msg, errPullingChart)
 				}
 				return ctrl.Result{}, errPullingChart
 			}
-			defer os.RemoveAll(strings.Split(chartPath, "/")[0])
+			defer releaseChart()
 			r.Log.Info(fmt.Sprintf("%v: downloaded chart at %v", req.NamespacedName, chartPath))
 			installOpts := getReleaseInstallOptions(cr)
-			_, errInstallingChart := helmV3.InstallRelease(chartPath, installOpts, cr.Spec.ValuesOverride.V)
+			markReconciling(cr, "Installing", fmt.Sprintf("installing %v-%v", cr.Spec.Chart, cr.Spec.Version))
+			if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+				return ctrl.Result{}, err
+			}
+			installedRelease, errInstallingChart := helmV3.InstallRelease(chartPath, installOpts, values)
+			if installedRelease != nil {
+				recordReleaseLocation(cr)
+			}
 			if errInstallingChart != nil {
 				r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
 					Channel:   notificationChannel,
@@ -171,11 +298,19 @@ func (r *ReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 						"Namespace": cr.GetNamespace(),
 						"Reason":    fmt.Sprintf("Release failed to install :bug: :construction: %v", errInstallingChart)},
 				})
-				cr.Status.FailureCount++
-				if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
-					return ctrl.Result{}, err
+				markFailed(cr, "InstallFailed", errInstallingChart)
+				recordHistory(cr, installedRelease, coverosv1alpha1.HistoryOutcomeFailed, errInstallingChart.Error())
+				recordLatestRevision(cr, helmV3)
+				return r.retryLater(cr, errInstallingChart)
+			}
+			// polled releases are tested once their resources are ready
+			if testsEnabled(cr) && !pollReadiness(cr) {
+				if errTesting := r.runTests(cr, helmV3, installedRelease); errTesting != nil {
+					markFailed(cr, "TestsFailed", errTesting)
+					recordHistory(cr, installedRelease, coverosv1alpha1.HistoryOutcomeFailed, errTesting.Error())
+					recordLatestRevision(cr, helmV3)
+					return r.retryLater(cr, errTesting)
 				}
-				return ctrl.Result{}, errInstallingChart
 			}
 			// force requeue to get new release state
 			r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
@@ -187,67 +322,190 @@ func (r *ReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 					"Namespace": cr.GetNamespace(),
 					"Reason":    "Release installed successfully :smile:"},
 			})
-			cr.Status.Installed = true
-			cr.Status.FailureCount = 0
-			if err := utils.UpdateCrStatus(cr, r.Client); err != nil {
-				return ctrl.Result{}, err
+			cr.Status.PostRenderersHash = postRenderersHash(cr)
+			if pollReadiness(cr) {
+				awaitReadiness(cr, installedRelease)
+				recordHistory(cr, installedRelease, coverosv1alpha1.HistoryOutcomeInstalled, "")
+				return ctrl.Result{RequeueAfter: readinessPollInterval}, utils.UpdateCrStatus(cr, r.Client)
 			}
-			return ctrl.Result{Requeue: true}, r.Client.Status().Update(context.TODO(), cr)
+			markReady(cr, installedRelease, "InstallSucceeded", "Release installed successfully")
+			recordHistory(cr, installedRelease, coverosv1alpha1.HistoryOutcomeInstalled, "")
+			return ctrl.Result{Requeue: true}, utils.UpdateCrStatus(cr, r.Client)
 		}
 		return ctrl.Result{}, errGettingReleaseInfo
 	}
 
+	recordReleaseLocation(cr)
 	if isReleasePending(releaseInfo) {
 		r.Log.Info(fmt.Sprintf("%v is still in '%v' phase, checking back in a few..", req.NamespacedName, releaseInfo.Info.Status))
+		markReconciling(cr, "HelmReleasePending", fmt.Sprintf("helm release is %v", releaseInfo.Info.Status))
+		if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+			return ctrl.Result{}, err
+		}
 		return ctrl.Result{Requeue: true, RequeueAfter: 30 * time.Second}, nil
 	}
 
+	// an adopted helm release is left alone until it matches the spec or the differences are confirmed
+	if adoptionPending(cr) && !r.checkAdoption(cr, releaseInfo, values) {
+		if !reflect.DeepEqual(originalStatus, &cr.Status) {
+			return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	releaseInfo, outOfBand, errCheckingOutOfBand := r.checkOutOfBand(cr, helmV3, releaseInfo)
+	if errCheckingOutOfBand != nil {
+		return ctrl.Result{}, errCheckingOutOfBand
+	}
+
+	if cr.Spec.RollbackTo > 0 {
+		if cr.Status.RolledBackTo == cr.Spec.RollbackTo {
+			// hold the rolled back revision until spec.rollbackTo is cleared
+			markReady(cr, releaseInfo, "RolledBack", fmt.Sprintf("Release is held at revision %v", cr.Spec.RollbackTo))
+			if !reflect.DeepEqual(originalStatus, &cr.Status) {
+				return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+			}
+			return ctrl.Result{}, nil
+		}
+
+		r.Log.Info(fmt.Sprintf("%v rolling back to revision number: %v", req.NamespacedName, cr.Spec.RollbackTo))
+		markReconciling(cr, "RollingBack", fmt.Sprintf("rolling back to revision %v", cr.Spec.RollbackTo))
+		if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+			return ctrl.Result{}, err
+		}
+		rolledBackRelease, errRollingBack := r.rollback(cr, helmV3, cr.Spec.RollbackTo)
+		if errRollingBack != nil {
+			r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+				Channel:   notificationChannel,
+				Title:     req.NamespacedName.String(),
+				EventType: cNotifyLib.Failure,
+				Fields: map[string]string{
+					"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+					"Namespace": cr.GetNamespace(),
+					"Reason":    fmt.Sprintf("Release failed to roll back to revision %v :bug: :construction: %v", cr.Spec.RollbackTo, errRollingBack)},
+			})
+			markFailed(cr, "RollbackFailed", errRollingBack)
+			recordHistory(cr, rolledBackRelease, coverosv1alpha1.HistoryOutcomeFailed, errRollingBack.Error())
+			recordLatestRevision(cr, helmV3)
+			return r.retryLater(cr, errRollingBack)
+		}
+		r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+			Channel:   notificationChannel,
+			Title:     req.NamespacedName.String(),
+			EventType: cNotifyLib.Success,
+			Fields: map[string]string{
+				"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+				"Namespace": cr.GetNamespace(),
+				"Reason":    fmt.Sprintf("Release rolled back to revision %v as revision %v :rewind:", cr.Spec.RollbackTo, rolledBackRelease.Version)},
+		})
+		r.Log.Info(fmt.Sprintf("%v successfully rolled back to revision number: %v, new revision number: %v", req.NamespacedName, cr.Spec.RollbackTo, rolledBackRelease.Version))
+		cr.Status.RolledBackTo = cr.Spec.RollbackTo
+		markReady(cr, rolledBackRelease, "RolledBack", fmt.Sprintf("Release is held at revision %v", cr.Spec.RollbackTo))
+		recordHistory(cr, rolledBackRelease, coverosv1alpha1.HistoryOutcomeRolledBack, fmt.Sprintf("rolled back to revision %v", cr.Spec.RollbackTo))
+		return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+	}
+	// rollbackTo was cleared, follow the spec again
+	cr.Status.RolledBackTo = 0
+
+	if outOfBand {
+		r.Log.Info(fmt.Sprintf("%v keeping out of band revision %v until the spec changes", req.NamespacedName, releaseInfo.Version))
+		markOutOfBandHeld(cr, releaseInfo)
+		if !reflect.DeepEqual(originalStatus, &cr.Status) {
+			return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+		}
+		return ctrl.Result{}, nil
+	}
+
 	releaseValuesOverride := releaseInfo.Config
 	if releaseValuesOverride == nil {
 		releaseValuesOverride = map[string]interface{}{}
 	}
 
-	valuesInSync := reflect.DeepEqual(cr.Spec.ValuesOverride.V, releaseValuesOverride)
+	valuesInSync := reflect.DeepEqual(values, releaseValuesOverride)
 	chartVersionInSync := cr.Spec.Version == releaseInfo.Chart.Metadata.Version
 	chartNameInSync := justChartName == releaseInfo.Chart.Metadata.Name
-	//releaseRevisionInSync := cr.Status.RevisionNumber == releaseInfo.Version
+	// helm does not keep post renderers in the release, compare with the ones genoa last applied
+	postRenderersInSync := postRenderersHash(cr) == cr.Status.PostRenderersHash
+	specInSync := chartNameInSync && chartVersionInSync && valuesInSync && postRenderersInSync
+	correctDrift := specInSync && r.checkDrift(cr, helmV3, releaseInfo)
+
+	if canaryInProgress(cr) && (cr.Spec.Canary == nil || specInSync) {
+		if errRemovingCanary := r.abandonCanary(cr, helmV3, "nothing left to promote"); errRemovingCanary != nil {
+			return ctrl.Result{}, errRemovingCanary
+		}
+	}
 
-	if !chartNameInSync || !chartVersionInSync || !valuesInSync {
+	if !specInSync || correctDrift {
 		r.Log.Info(fmt.Sprintf("%v release values in sync with installed values: %v", req.NamespacedName, valuesInSync))
 		r.Log.Info(fmt.Sprintf("%v release chart version in sync with installed chart version: %v", req.NamespacedName, chartVersionInSync))
 		r.Log.Info(fmt.Sprintf("%v release chart name in sync with installed chart name: %v", req.NamespacedName, chartNameInSync))
+		r.Log.Info(fmt.Sprintf("%v release post renderers in sync with applied post renderers: %v", req.NamespacedName, postRenderersInSync))
 
-		chartPath, errPullingChart := r.pullChart(hrNamespace, hrName, repoAlias, chartName, cr.Spec.Version, helmV3)
+		if remediationExhausted(cr) {
+			r.Log.Info(fmt.Sprintf("%v upgrade retries ran out, holding the remediated release until the spec changes", req.NamespacedName))
+			return ctrl.Result{}, nil
+		}
+
+		// drift corrections re-apply what was already approved
+		if !specInSync {
+			approved, errCheckingApproval := r.checkApproval(cr, releaseInfo, values)
+			if errCheckingApproval != nil {
+				return ctrl.Result{}, errCheckingApproval
+			}
+			if !approved {
+				// requeued when the approval annotation or a ReleaseApproval shows up
+				if !reflect.DeepEqual(originalStatus, &cr.Status) {
+					return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+				}
+				return ctrl.Result{}, nil
+			}
+		}
+
+		changeAllowed, retryAfter, errCheckingSchedule := r.checkSchedule(cr, releaseInfo, values)
+		if errCheckingSchedule != nil {
+			return ctrl.Result{}, errCheckingSchedule
+		}
+		if !changeAllowed {
+			if !reflect.DeepEqual(originalStatus, &cr.Status) {
+				return ctrl.Result{RequeueAfter: retryAfter}, utils.UpdateCrStatus(cr, r.Client)
+			}
+			return ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+
+		chartPath, releaseChart, errPullingChart := r.pullChart(cr, repoAlias, chartName, cr.Spec.Version, helmV3)
 		if errPullingChart != nil {
 			if _, ok := errPullingChart.(pkg.ErrorHelmRepoNeedsRefresh); ok {
 				r.Log.Info(fmt.Sprintf("refreshing helm repo index"))
-				return ctrl.Result{Requeue: true}, helmV3.RefreshRepoIndex(repoAlias)
+				return ctrl.Result{Requeue: true}, helmV3.RefreshRepoIndex(repoAlias, r.getRepoOptions(repoAlias))
+			}
+			if reason, msg := pullChartFailureReason(errPullingChart); reason != "" {
+				return r.chartPullFailed(cr, reason, msg, errPullingChart)
 			}
 			return ctrl.Result{}, errPullingChart
 		}
-		defer os.RemoveAll(strings.Split(chartPath, "/")[0])
-
-		// TODO: need to figure out what to when we get immutable error from a revision rollback
-		/**
-		Example: error
-		Service is invalid: spec.clusterIP: Invalid value: "":
-		field is immutable &&
-		failed to replace object: Service "jenkins" is invalid: spec.clusterIP: Invalid value: "": field is immutable
-		*/
-		//if !releaseRevisionInSync {
-		//	r.Log.Info(fmt.Sprintf("%v rolling back to revision number: %v", req.NamespacedName, cr.Status.RevisionNumber))
-		//	newRevisionNumber := (releaseInfo.Version - cr.Status.RevisionNumber) + cr.Status.RevisionNumber + 1
-		//	rollBackOpts := v3.RollbackToRevisionOptions{Force: true, ToRevision: cr.Status.RevisionNumber}
-		//	if errRollingBack := helmV3.RollbackToRevision(hrName, rollBackOpts); errRollingBack != nil {
-		//		return ctrl.Result{}, errRollingBack
-		//	}
-		//	r.Log.Info(fmt.Sprintf("%v successfully rolled back to revision number: %v", req.NamespacedName, cr.Status.RevisionNumber))
-		//	cr.Status.RevisionNumber = newRevisionNumber
-		//	r.Log.Info(fmt.Sprintf("%v new revision number: %v", req.NamespacedName, newRevisionNumber))
-		//	return ctrl.Result{}, r.Client.Status().Update(context.TODO(), cr)
-		//}
+		defer releaseChart()
+
+		// canaries only roll out spec changes, drift corrections re-apply what the primary release already runs
+		if cr.Spec.Canary != nil && !specInSync && !cr.Spec.DryRun {
+			promote, result, errRunningCanary := r.runCanary(cr, helmV3, chartPath, values)
+			if errRunningCanary != nil {
+				return ctrl.Result{}, errRunningCanary
+			}
+			if !promote {
+				if !reflect.DeepEqual(originalStatus, &cr.Status) {
+					return result, utils.UpdateCrStatus(cr, r.Client)
+				}
+				return result, nil
+			}
+		}
+
 		upgradeOpts := getReleaseUpgradeOptions(cr)
-		if _, errUpgradingRelease := helmV3.UpgradeRelease(chartPath, upgradeOpts, cr.Spec.ValuesOverride.V); errUpgradingRelease != nil {
+		markReconciling(cr, "Upgrading", fmt.Sprintf("upgrading to %v-%v", cr.Spec.Chart, cr.Spec.Version))
+		if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+			return ctrl.Result{}, err
+		}
+		upgradedRelease, errUpgradingRelease := helmV3.UpgradeRelease(chartPath, upgradeOpts, values)
+		if errUpgradingRelease != nil {
 
 			r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
 				Channel:   notificationChannel,
@@ -258,11 +516,25 @@ func (r *ReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 					"Namespace": cr.GetNamespace(),
 					"Reason":    fmt.Sprintf("Release failed to upgrade :bug: :construction: %v", errUpgradingRelease)},
 			})
-			cr.Status.FailureCount++
-			if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
-				return ctrl.Result{}, err
+			recordHistory(cr, upgradedRelease, coverosv1alpha1.HistoryOutcomeFailed, errUpgradingRelease.Error())
+			recordLatestRevision(cr, helmV3)
+			if remediationEnabled(cr) {
+				return r.remediateFailedUpgrade(cr, helmV3, chartPath, values, errUpgradingRelease)
+			}
+			markFailed(cr, "UpgradeFailed", errUpgradingRelease)
+			return r.retryLater(cr, errUpgradingRelease)
+		}
+		if testsEnabled(cr) && !pollReadiness(cr) {
+			// a failing test is a failed rollout, the previous revision is still the last good one
+			if errTesting := r.runTests(cr, helmV3, upgradedRelease); errTesting != nil {
+				recordHistory(cr, upgradedRelease, coverosv1alpha1.HistoryOutcomeFailed, errTesting.Error())
+				recordLatestRevision(cr, helmV3)
+				if remediationEnabled(cr) {
+					return r.remediateFailedUpgrade(cr, helmV3, chartPath, values, errTesting)
+				}
+				markFailed(cr, "TestsFailed", errTesting)
+				return r.retryLater(cr, errTesting)
 			}
-			return ctrl.Result{}, errUpgradingRelease
 		}
 		r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
 			Channel:   notificationChannel,
@@ -274,8 +546,61 @@ func (r *ReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 				"Reason":    "Release upgraded successfully :confetti_ball:"},
 		})
 		r.Log.Info(fmt.Sprintf("Successfully upgraded helm release for %v", req.NamespacedName))
-		return ctrl.Result{}, nil
+		if canaryInProgress(cr) {
+			if errRemovingCanary := r.finishCanary(cr, helmV3); errRemovingCanary != nil {
+				return ctrl.Result{}, errRemovingCanary
+			}
+		}
+		if correctDrift {
+			markDriftCorrected(cr)
+		}
+		cr.Status.PostRenderersHash = postRenderersHash(cr)
+		if pollReadiness(cr) {
+			awaitReadiness(cr, upgradedRelease)
+			recordHistory(cr, upgradedRelease, coverosv1alpha1.HistoryOutcomeUpgraded, "")
+			return ctrl.Result{RequeueAfter: readinessPollInterval}, utils.UpdateCrStatus(cr, r.Client)
+		}
+		markReady(cr, upgradedRelease, "UpgradeSucceeded", "Release upgraded successfully")
+		recordHistory(cr, upgradedRelease, coverosv1alpha1.HistoryOutcomeUpgraded, "")
+		return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+	}
+
+	// resources of a revision installed without waiting
+	if cr.Status.Readiness != nil && cr.Status.Readiness.Revision != releaseInfo.Version {
+		cr.Status.Readiness = nil
+	}
+	if cr.Status.Readiness != nil {
+		readiness, errCheckingReadiness := r.checkReadiness(cr, helmV3, releaseInfo)
+		if errCheckingReadiness != nil {
+			return ctrl.Result{}, errCheckingReadiness
+		}
+		if readiness.errNotReady != nil {
+			markFailed(cr, "ResourcesNotReady", readiness.errNotReady)
+			if readiness.failed {
+				markResourcesFailed(cr, readiness.errNotReady)
+			}
+			return r.retryLater(cr, readiness.errNotReady)
+		}
+		if !readiness.ready {
+			if !reflect.DeepEqual(originalStatus, &cr.Status) {
+				return ctrl.Result{RequeueAfter: readinessPollInterval}, utils.UpdateCrStatus(cr, r.Client)
+			}
+			return ctrl.Result{RequeueAfter: readinessPollInterval}, nil
+		}
 	}
 
+	// retries of failed tests, and revisions genoa did not test yet
+	if testsEnabled(cr) && !testsPassed(cr, releaseInfo) {
+		if errTesting := r.runTests(cr, helmV3, releaseInfo); errTesting != nil {
+			markFailed(cr, "TestsFailed", errTesting)
+			return r.retryLater(cr, errTesting)
+		}
+	}
+
+	// in sync, only write the status when something actually changed so the periodic resync stays cheap
+	markReady(cr, releaseInfo, "ReconciliationSucceeded", "Release is in sync")
+	if !reflect.DeepEqual(originalStatus, &cr.Status) {
+		return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+	}
 	return ctrl.Result{}, nil
 }
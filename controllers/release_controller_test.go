@@ -8,10 +8,12 @@ import (
 	"github.com/ghodss/yaml"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/storage/driver"
 	"io/ioutil"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -178,3 +180,174 @@ var _ = Describe("Invalid test Reconciles", func() {
 		})
 	}
 })
+
+var _ = Describe("Templated values", func() {
+	testRelease := &coverosv1alpha1.Release{}
+	if err := yaml.Unmarshal([]byte(`
+apiVersion: coveros.apps.com/v1alpha1
+kind: Release
+metadata:
+  name: jenkins-templated
+  namespace: default
+spec:
+  chart: stable/jenkins
+  version: 2.4.1
+  wait: false
+  maxRetries: 1
+  templateValues: true
+  values:
+    master:
+      adminUser: "{{ .Release.Name }}"
+      adminPassword: admin
+    persistence:
+      enabled: false
+`), testRelease); err != nil {
+		Fail("Failed to parse templated test release")
+	}
+	namespacedName := types.NamespacedName{Name: testRelease.GetName(), Namespace: testRelease.GetNamespace()}
+
+	When(fmt.Sprintf("%v release CR with values templates is created", namespacedName), func() {
+		It("installs the rendered values and does not upgrade on resyncs", func() {
+			Expect(k8sClient.Create(context.TODO(), testRelease)).Should(Succeed())
+			releaseFromCluster := &coverosv1alpha1.Release{}
+			Eventually(func() bool {
+				if err := k8sClient.Get(context.TODO(), namespacedName, releaseFromCluster); err != nil {
+					return false
+				}
+				return releaseFromCluster.Status.Installed
+			}, 30*time.Second, 5*time.Second).Should(BeTrue())
+
+			helmClient, errCreatingHelmClient := v3.NewActionConfig(testRelease.GetNamespace(), cfg)
+			Expect(errCreatingHelmClient).NotTo(HaveOccurred())
+			releaseInfo, errGettingRelease := helmClient.GetRelease(testRelease.GetName())
+			Expect(errGettingRelease).NotTo(HaveOccurred())
+			Expect(releaseInfo.Config["master"]).Should(HaveKeyWithValue("adminUser", "jenkins-templated"))
+			Expect(releaseFromCluster.Spec.ValuesOverride.V["master"]).Should(HaveKeyWithValue("adminUser", "{{ .Release.Name }}"))
+
+			By("resyncing without creating new helm revisions", func() {
+				Consistently(func() int {
+					current, errGettingCurrent := helmClient.GetRelease(testRelease.GetName())
+					if errGettingCurrent != nil {
+						return 0
+					}
+					return current.Version
+				}, 20*time.Second, 5*time.Second).Should(Equal(releaseInfo.Version))
+			})
+
+			Expect(k8sClient.Delete(context.TODO(), testRelease)).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(context.TODO(), namespacedName, &coverosv1alpha1.Release{}))
+			}, 30*time.Second, 5*time.Second).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Out of band revisions", func() {
+	for _, policy := range []string{coverosv1alpha1.OutOfBandAlert, coverosv1alpha1.OutOfBandAdopt, coverosv1alpha1.OutOfBandRevert} {
+		policy := policy
+		testRelease := &coverosv1alpha1.Release{}
+		if err := yaml.Unmarshal([]byte(fmt.Sprintf(`
+apiVersion: coveros.apps.com/v1alpha1
+kind: Release
+metadata:
+  name: jenkins-out-of-band-%v
+  namespace: default
+spec:
+  chart: stable/jenkins
+  version: 2.4.1
+  wait: false
+  maxRetries: 1
+  outOfBandPolicy: %v
+  values:
+    master:
+      adminPassword: admin
+    persistence:
+      enabled: false
+`, strings.ToLower(policy), policy)), testRelease); err != nil {
+			Fail("Failed to parse out of band test release")
+		}
+		namespacedName := types.NamespacedName{Name: testRelease.GetName(), Namespace: testRelease.GetNamespace()}
+
+		When(fmt.Sprintf("%v release is upgraded outside of genoa under the %v policy", namespacedName, policy), func() {
+			It("handles the manual helm revision according to the policy", func() {
+				Expect(k8sClient.Create(context.TODO(), testRelease)).Should(Succeed())
+				Eventually(func() bool {
+					releaseFromCluster := &coverosv1alpha1.Release{}
+					if err := k8sClient.Get(context.TODO(), namespacedName, releaseFromCluster); err != nil {
+						return false
+					}
+					return releaseFromCluster.Status.Installed
+				}, 30*time.Second, 5*time.Second).Should(BeTrue())
+
+				helmClient, errCreatingHelmClient := v3.NewActionConfig(testRelease.GetNamespace(), cfg)
+				Expect(errCreatingHelmClient).NotTo(HaveOccurred())
+				releaseInfo, errGettingRelease := helmClient.GetRelease(testRelease.GetName())
+				Expect(errGettingRelease).NotTo(HaveOccurred())
+
+				chartDir, errCreatingDir := ioutil.TempDir("", "genoa-out-of-band")
+				Expect(errCreatingDir).NotTo(HaveOccurred())
+				defer os.RemoveAll(chartDir)
+				chartPath, errSavingChart := chartutil.Save(releaseInfo.Chart, chartDir)
+				Expect(errSavingChart).NotTo(HaveOccurred())
+				manualValues := map[string]interface{}{
+					"master":      map[string]interface{}{"adminPassword": "changed-by-hand"},
+					"persistence": map[string]interface{}{"enabled": false},
+				}
+				manualRelease, errUpgrading := helmClient.UpgradeRelease(chartPath, v3.UpgradeOptions{
+					Namespace:   testRelease.GetNamespace(),
+					ReleaseName: testRelease.GetName(),
+				}, manualValues)
+				Expect(errUpgrading).NotTo(HaveOccurred())
+				Expect(manualRelease.Version).Should(Equal(releaseInfo.Version + 1))
+
+				currentRelease := func() (int, string) {
+					current, errGettingCurrent := helmClient.GetRelease(testRelease.GetName())
+					if errGettingCurrent != nil {
+						return 0, ""
+					}
+					master, _ := current.Config["master"].(map[string]interface{})
+					password, _ := master["adminPassword"].(string)
+					return current.Version, password
+				}
+
+				if policy == coverosv1alpha1.OutOfBandRevert {
+					By("rolling back to the revision genoa created", func() {
+						Eventually(func() string {
+							_, password := currentRelease()
+							return password
+						}, 30*time.Second, 5*time.Second).Should(Equal("admin"))
+					})
+				} else {
+					By("keeping the manual revision while the spec is unchanged", func() {
+						Consistently(func() int {
+							version, _ := currentRelease()
+							return version
+						}, 20*time.Second, 5*time.Second).Should(Equal(manualRelease.Version))
+						_, password := currentRelease()
+						Expect(password).Should(Equal("changed-by-hand"))
+					})
+
+					By("applying the spec over the manual revision once the spec changes", func() {
+						Eventually(func() error {
+							releaseFromCluster := &coverosv1alpha1.Release{}
+							if err := k8sClient.Get(context.TODO(), namespacedName, releaseFromCluster); err != nil {
+								return err
+							}
+							releaseFromCluster.Spec.ValuesOverride.V["master"] = map[string]interface{}{"adminPassword": "changed-in-spec"}
+							return k8sClient.Update(context.TODO(), releaseFromCluster)
+						}, 30*time.Second, 5*time.Second).Should(Succeed())
+						Eventually(func() string {
+							_, password := currentRelease()
+							return password
+						}, 30*time.Second, 5*time.Second).Should(Equal("changed-in-spec"))
+					})
+				}
+
+				Expect(k8sClient.Delete(context.TODO(), testRelease)).Should(Succeed())
+				Eventually(func() bool {
+					return apierrors.IsNotFound(k8sClient.Get(context.TODO(), namespacedName, &coverosv1alpha1.Release{}))
+				}, 30*time.Second, 5*time.Second).Should(BeTrue())
+			})
+		})
+	}
+})
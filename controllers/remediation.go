@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"time"
+)
+
+// defaultRemediationWaitTimeout matches the helm default for --wait
+const defaultRemediationWaitTimeout = 5 * time.Minute
+
+func remediationEnabled(cr *v1alpha1.Release) bool {
+	return cr.Spec.Remediation != nil && cr.Spec.Remediation.Strategy != "" && cr.Spec.Remediation.Strategy != v1alpha1.RemediationNone
+}
+
+// remediationExhausted reports whether the current generation used up its upgrade retries,
+// the release stays at its remediated state until the spec changes
+func remediationExhausted(cr *v1alpha1.Release) bool {
+	return remediationEnabled(cr) && cr.Status.Remediation != nil &&
+		cr.Status.Remediation.Generation == cr.GetGeneration() &&
+		cr.Status.Remediation.Attempts > cr.Spec.Remediation.Retries
+}
+
+// remediateFailedUpgrade repairs the helm release after a failed upgrade using the Release remediation strategy.
+// The upgrade is retried while the strategy has retries left.
+func (r *ReleaseReconciler) remediateFailedUpgrade(cr *v1alpha1.Release, actionConfig *v3.HelmV3, chartPath string, values map[string]interface{},
+	errUpgradingRelease error) (ctrl.Result, error) {
+	strategy := cr.Spec.Remediation.Strategy
+	generation := cr.GetGeneration()
+	if cr.Status.Remediation == nil || cr.Status.Remediation.Generation != generation || cr.Status.Remediation.Strategy != strategy {
+		cr.Status.Remediation = &v1alpha1.RemediationStatus{Strategy: strategy, Generation: generation}
+	}
+	cr.Status.Remediation.Attempts++
+	r.Log.Info(fmt.Sprintf("%v/%v remediating failed upgrade with %v, attempt %v", cr.GetNamespace(), cr.GetName(), strategy, cr.Status.Remediation.Attempts))
+
+	var remediatedRelease *release.Release
+	var errRemediating error
+	var outcome string
+	switch strategy {
+	case v1alpha1.RemediationRollback:
+		if cr.Status.HelmRevision == 0 {
+			errRemediating = fmt.Errorf("no successful revision to roll back to")
+			break
+		}
+		outcome = v1alpha1.HistoryOutcomeRolledBack
+		remediatedRelease, errRemediating = r.rollback(cr, actionConfig, cr.Status.HelmRevision)
+	case v1alpha1.RemediationReinstall:
+		outcome = v1alpha1.HistoryOutcomeInstalled
+		remediatedRelease, errRemediating = r.reinstall(cr, actionConfig, chartPath, values)
+	default:
+		errRemediating = fmt.Errorf("unknown remediation strategy %v", strategy)
+	}
+
+	if errRemediating != nil {
+		r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+			Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+			Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+			EventType: cNotifyLib.Failure,
+			Fields: map[string]string{
+				"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+				"Namespace": cr.GetNamespace(),
+				"Reason":    fmt.Sprintf("Release failed to upgrade and %v remediation failed :fire: %v", strategy, errRemediating)},
+		})
+		markFailed(cr, "RemediationFailed", fmt.Errorf("upgrade failed: %v, %v remediation failed: %v", errUpgradingRelease, strategy, errRemediating))
+		recordHistory(cr, remediatedRelease, v1alpha1.HistoryOutcomeFailed, errRemediating.Error())
+		recordLatestRevision(cr, actionConfig)
+		return r.retryLater(cr, errRemediating)
+	}
+
+	r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+		Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+		Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+		EventType: cNotifyLib.Success,
+		Fields: map[string]string{
+			"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+			"Namespace": cr.GetNamespace(),
+			"Reason":    fmt.Sprintf("Release failed to upgrade and was remediated with %v :ambulance:", strategy)},
+	})
+	recordHistory(cr, remediatedRelease, outcome, fmt.Sprintf("%v remediation of failed upgrade", strategy))
+
+	// a reinstall applies the spec, there is nothing left to retry
+	if strategy == v1alpha1.RemediationReinstall {
+		cr.Status.PostRenderersHash = postRenderersHash(cr)
+		markReady(cr, remediatedRelease, "Reinstalled", "Release reinstalled after a failed upgrade")
+		return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+	}
+
+	markRemediated(cr, remediatedRelease, errUpgradingRelease)
+	if remediationExhausted(cr) {
+		return ctrl.Result{}, utils.UpdateCrStatus(cr, r.Client)
+	}
+	return ctrl.Result{Requeue: true}, utils.UpdateCrStatus(cr, r.Client)
+}
+
+// reinstall uninstalls the helm release and installs the chart again from scratch
+func (r *ReleaseReconciler) reinstall(cr *v1alpha1.Release, actionConfig *v3.HelmV3, chartPath string, values map[string]interface{}) (*release.Release, error) {
+	if _, errUninstalling := actionConfig.UninstallRelease(helmReleaseName(cr)); errUninstalling != nil {
+		return nil, errUninstalling
+	}
+	installOpts := getReleaseInstallOptions(cr)
+	installOpts.Wait = true
+	if installOpts.Timeout == 0 {
+		// install options take the timeout in seconds
+		installOpts.Timeout = defaultRemediationWaitTimeout / time.Second
+	}
+	return actionConfig.InstallRelease(chartPath, installOpts, values)
+}
+
+// markRemediated records a release that was repaired but does not match the spec, it is healthy but not ready
+func markRemediated(cr *v1alpha1.Release, releaseInfo *release.Release, errUpgradingRelease error) {
+	generation := cr.GetGeneration()
+	cr.Status.FailureCount = 0
+	cr.Status.ObservedGeneration = generation
+	cr.Status.LastError = errUpgradingRelease.Error()
+	if releaseInfo != nil {
+		cr.Status.HelmRevision = releaseInfo.Version
+		if releaseInfo.Chart != nil && releaseInfo.Chart.Metadata != nil {
+			cr.Status.ChartName = releaseInfo.Chart.Metadata.Name
+			cr.Status.ChartVersion = releaseInfo.Chart.Metadata.Version
+			cr.Status.LastAppliedRevision = appliedRevision(releaseInfo.Chart.Metadata.Version, releaseInfo.Config, cr.Status.PostRenderersHash)
+		}
+	}
+	message := fmt.Sprintf("upgrade to %v failed and was remediated: %v", cr.Spec.Version, errUpgradingRelease)
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, "UpgradeRemediated", message, generation)
+	if remediationExhausted(cr) {
+		cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionFalse, "RemediationRetriesExhausted", "", generation)
+		cr.Status.SetCondition(v1alpha1.ConditionStalled, metav1.ConditionTrue, "RemediationRetriesExhausted",
+			"upgrade retries ran out, update the Release spec to try again", generation)
+		return
+	}
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionTrue, "RetryingUpgrade", message, generation)
+}
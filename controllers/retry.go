@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"time"
+)
+
+const (
+	defaultMaxRetries    = 5
+	defaultBackoffBase   = 10 * time.Second
+	defaultBackoffMax    = 10 * time.Minute
+	defaultBackoffJitter = 10
+)
+
+func maxRetries(cr *v1alpha1.Release) int {
+	if cr.Spec.MaxRetries == nil {
+		return defaultMaxRetries
+	}
+	return *cr.Spec.MaxRetries
+}
+
+func retryBudgetExhausted(cr *v1alpha1.Release) bool {
+	return cr.Status.FailureCount > maxRetries(cr)
+}
+
+// retryNowRequested is true when the retry-now annotation changed since genoa last acted on it
+func retryNowRequested(cr *v1alpha1.Release) bool {
+	retryNow := cr.GetAnnotations()[utils.RetryNowAnnotation]
+	return retryNow != "" && retryNow != cr.Status.LastRetryNow
+}
+
+// resetRetries gives the Release a fresh retry budget
+func resetRetries(cr *v1alpha1.Release, reason string) {
+	cr.Status.FailureCount = 0
+	cr.Status.NextRetryTime = nil
+	if cr.Status.IsConditionTrue(v1alpha1.ConditionStalled) {
+		cr.Status.SetCondition(v1alpha1.ConditionStalled, metav1.ConditionFalse, reason, "", cr.GetGeneration())
+	}
+}
+
+// backoffDelay is the wait before retrying after the failures so far, invalid durations fall back to the defaults
+func backoffDelay(cr *v1alpha1.Release) time.Duration {
+	base, max, jitter := defaultBackoffBase, defaultBackoffMax, defaultBackoffJitter
+	if backoff := cr.Spec.Backoff; backoff != nil {
+		if parsed, err := time.ParseDuration(backoff.Base); err == nil && parsed > 0 {
+			base = parsed
+		}
+		if parsed, err := time.ParseDuration(backoff.Max); err == nil && parsed > 0 {
+			max = parsed
+		}
+		if backoff.JitterPercent != nil {
+			jitter = *backoff.JitterPercent
+		}
+	}
+	return utils.BackoffDelay(base, max, jitter, cr.Status.FailureCount)
+}
+
+// retryLater saves a failed attempt and schedules the next one on the backoff schedule, none once the retry budget is spent
+func (r *ReleaseReconciler) retryLater(cr *v1alpha1.Release, errFailed error) (ctrl.Result, error) {
+	r.Log.Error(errFailed, fmt.Sprintf("%v/%v failed, attempt %v of %v", cr.GetNamespace(), cr.GetName(), cr.Status.FailureCount, maxRetries(cr)+1))
+	var result ctrl.Result
+	if retryBudgetExhausted(cr) {
+		cr.Status.NextRetryTime = nil
+	} else {
+		delay := backoffDelay(cr)
+		nextRetryTime := metav1.NewTime(time.Now().Add(delay))
+		cr.Status.NextRetryTime = &nextRetryTime
+		result = ctrl.Result{RequeueAfter: delay}
+	}
+	if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+		return ctrl.Result{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/schedule"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/release"
+	v1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+)
+
+// scheduleRetryInterval is how often a Release whose schedule is missing or invalid checks it again
+const scheduleRetryInterval = 5 * time.Minute
+
+// getDeploymentSchedule returns the schedule the Release follows, an empty name when there is none. That is its own schedule
+// in the Release namespace, or the one the namespace of the helm release names with its annotation, in that namespace.
+func (r *ReleaseReconciler) getDeploymentSchedule(cr *v1alpha1.Release) (types.NamespacedName, error) {
+	if cr.Spec.DeploymentSchedule != "" {
+		return types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.Spec.DeploymentSchedule}, nil
+	}
+	namespace := &v1.Namespace{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: releaseNamespace(cr)}, namespace); err != nil {
+		if apiErrors.IsNotFound(err) {
+			return types.NamespacedName{}, nil
+		}
+		return types.NamespacedName{}, err
+	}
+	return types.NamespacedName{Namespace: namespace.GetName(), Name: namespace.GetAnnotations()[utils.DeploymentScheduleAnnotation]}, nil
+}
+
+// releasesUsingSchedule requeues the Releases that follow a DeploymentSchedule when it changes, so a window that opens
+// earlier or a lifted blackout does not wait for the requeue computed from the old schedule
+func (r *ReleaseReconciler) releasesUsingSchedule(o handler.MapObject) []reconcile.Request {
+	releases := &v1alpha1.ReleaseList{}
+	if err := r.Client.List(context.TODO(), releases); err != nil {
+		r.Log.Error(err, fmt.Sprintf("failed to list the releases using deployment schedule %v/%v", o.Meta.GetNamespace(), o.Meta.GetName()))
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range releases.Items {
+		cr := &releases.Items[i]
+		scheduleKey, err := r.getDeploymentSchedule(cr)
+		if err != nil {
+			r.Log.Error(err, fmt.Sprintf("failed to get the deployment schedule of %v/%v", cr.GetNamespace(), cr.GetName()))
+			continue
+		}
+		if scheduleKey.Namespace == o.Meta.GetNamespace() && scheduleKey.Name == o.Meta.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}})
+		}
+	}
+	return requests
+}
+
+// checkSchedule reports whether the Release may be installed or upgraded now.
+// When it may not the change is recorded as pending, along with how long to wait before checking again.
+func (r *ReleaseReconciler) checkSchedule(cr *v1alpha1.Release, releaseInfo *release.Release, values map[string]interface{}) (bool, time.Duration, error) {
+	scheduleKey, errGettingSchedule := r.getDeploymentSchedule(cr)
+	if errGettingSchedule != nil {
+		return false, 0, errGettingSchedule
+	}
+	if scheduleKey.Name == "" {
+		return true, 0, nil
+	}
+	scheduleName := scheduleKey.String()
+
+	deploymentSchedule := &v1alpha1.DeploymentSchedule{}
+	if err := r.Client.Get(context.TODO(), scheduleKey, deploymentSchedule); err != nil {
+		if apiErrors.IsNotFound(err) {
+			// never roll out a change the schedule might forbid
+			r.deferChange(cr, releaseInfo, values, fmt.Sprintf("deployment schedule %v not found", scheduleName), nil)
+			return false, scheduleRetryInterval, nil
+		}
+		return false, 0, err
+	}
+
+	var windows []schedule.Window
+	for _, window := range deploymentSchedule.Spec.Windows {
+		windows = append(windows, schedule.Window{Cron: window.Start, Duration: window.Duration})
+	}
+	var blackouts []schedule.Blackout
+	for _, blackout := range deploymentSchedule.Spec.Blackouts {
+		blackouts = append(blackouts, schedule.Blackout{Start: blackout.Start, End: blackout.End, Reason: blackout.Reason})
+	}
+	parsedSchedule, errParsingSchedule := schedule.New(deploymentSchedule.Spec.Timezone, windows, blackouts)
+	if errParsingSchedule != nil {
+		r.deferChange(cr, releaseInfo, values, fmt.Sprintf("deployment schedule %v is invalid: %v", scheduleName, errParsingSchedule), nil)
+		return false, scheduleRetryInterval, nil
+	}
+
+	now := time.Now()
+	allowed, reason := parsedSchedule.Allowed(now)
+	if allowed {
+		return true, 0, nil
+	}
+	next, found := parsedSchedule.NextAllowed(now)
+	if !found {
+		r.deferChange(cr, releaseInfo, values, fmt.Sprintf("%v, deployment schedule %v never opens again", reason, scheduleName), nil)
+		return false, scheduleRetryInterval, nil
+	}
+	notBefore := metav1.NewTime(next)
+	r.deferChange(cr, releaseInfo, values, fmt.Sprintf("%v of deployment schedule %v", reason, scheduleName), &notBefore)
+	return false, time.Until(next), nil
+}
+
+// deferChange records the chart version and values waiting to be rolled out, notifying the first time a change is held back
+func (r *ReleaseReconciler) deferChange(cr *v1alpha1.Release, releaseInfo *release.Release, values map[string]interface{}, reason string, notBefore *metav1.Time) {
+	pendingChange := newPendingChange(cr, releaseInfo, values, reason)
+	pendingChange.NotBefore = notBefore
+	previous := cr.Status.PendingChange
+	if previous == nil || previous.Hash != pendingChange.Hash || previous.Reason != pendingChange.Reason {
+		when := "until the schedule allows it"
+		if notBefore != nil {
+			when = "until " + notBefore.Format(time.RFC3339)
+		}
+		r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+			Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+			Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+			EventType: cNotifyLib.Warning,
+			Fields: map[string]string{
+				"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+				"Namespace": cr.GetNamespace(),
+				"Reason":    fmt.Sprintf("Release change deferred %v :calendar: %v", when, reason)},
+		})
+	}
+	r.Log.Info(fmt.Sprintf("%v/%v change deferred: %v", cr.GetNamespace(), cr.GetName(), reason))
+	cr.Status.PendingChange = pendingChange
+	markReconciling(cr, "OutsideDeploymentWindow", reason)
+}
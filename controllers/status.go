@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxReleaseHistory bounds status.history so the Release object stays small
+const maxReleaseHistory = 10
+
+// markReady records that the helm release matches the Release spec
+func markReady(cr *v1alpha1.Release, releaseInfo *release.Release, reason, message string) {
+	generation := cr.GetGeneration()
+	cr.Status.Installed = true
+	cr.Status.FailureCount = 0
+	cr.Status.NextRetryTime = nil
+	cr.Status.ObservedGeneration = generation
+	cr.Status.LastError = ""
+	cr.Status.PendingChange = nil
+	cr.Status.LastAppliedRevision = appliedRevisionWithHash(cr.Spec.Version, specValuesHash(cr), postRenderersHash(cr))
+	if releaseInfo != nil {
+		cr.Status.HelmRevision = releaseInfo.Version
+		if releaseInfo.Chart != nil && releaseInfo.Chart.Metadata != nil {
+			cr.Status.ChartName = releaseInfo.Chart.Metadata.Name
+			cr.Status.ChartVersion = releaseInfo.Chart.Metadata.Version
+			// a rolled back release does not match the spec, report what is actually applied
+			cr.Status.LastAppliedRevision = appliedRevision(releaseInfo.Chart.Metadata.Version, releaseInfo.Config, cr.Status.PostRenderersHash)
+		}
+	}
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionTrue, reason, message, generation)
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionFalse, reason, "", generation)
+	cr.Status.SetCondition(v1alpha1.ConditionStalled, metav1.ConditionFalse, reason, "", generation)
+	if cr.Status.GetCondition(v1alpha1.ConditionDrifted) == nil {
+		cr.Status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionFalse, reason, "", generation)
+	}
+}
+
+// markFailed records a failed attempt, the Release stalls once it runs out of retries
+func markFailed(cr *v1alpha1.Release, reason string, err error) {
+	generation := cr.GetGeneration()
+	cr.Status.FailureCount++
+	cr.Status.ObservedGeneration = generation
+	cr.Status.LastError = err.Error()
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reason, err.Error(), generation)
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionFalse, reason, "", generation)
+	if retryBudgetExhausted(cr) {
+		cr.Status.SetCondition(v1alpha1.ConditionStalled, metav1.ConditionTrue, "RetriesExhausted",
+			"reached max retries, change the Release or set the retry-now annotation to retry", generation)
+	}
+}
+
+// markReconciling records work in progress, a Release that is not ready yet is neither ready nor failed until it is done
+func markReconciling(cr *v1alpha1.Release, reason, message string) {
+	generation := cr.GetGeneration()
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionTrue, reason, message, generation)
+	if !cr.Status.IsConditionTrue(v1alpha1.ConditionReady) {
+		cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionUnknown, reason, message, generation)
+	}
+}
+
+// markDependencies records whether the Releases this one depends on are ready
+func markDependencies(cr *v1alpha1.Release, ready bool, reason, message string) {
+	generation := cr.GetGeneration()
+	if ready {
+		cr.Status.SetCondition(v1alpha1.ConditionDependenciesReady, metav1.ConditionTrue, reason, message, generation)
+		return
+	}
+	cr.Status.SetCondition(v1alpha1.ConditionDependenciesReady, metav1.ConditionFalse, reason, message, generation)
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, reason, message, generation)
+}
+
+// recordHistory appends a helm action to the Release history, dropping the oldest entries past maxReleaseHistory.
+// The revision becomes the last one genoa produced. When helm did not hand back a release the entry describes the spec that was attempted.
+func recordHistory(cr *v1alpha1.Release, releaseInfo *release.Release, outcome, message string) {
+	entry := v1alpha1.ReleaseHistoryEntry{
+		ChartVersion: cr.Spec.Version,
+		ValuesHash:   specValuesHash(cr),
+		Timestamp:    metav1.Now(),
+		Outcome:      outcome,
+		Message:      message,
+	}
+	if releaseInfo != nil {
+		entry.Revision = releaseInfo.Version
+		cr.Status.LastProducedRevision = releaseInfo.Version
+		entry.ValuesHash = valuesHash(releaseInfo.Config)
+		if releaseInfo.Chart != nil && releaseInfo.Chart.Metadata != nil {
+			entry.ChartVersion = releaseInfo.Chart.Metadata.Version
+		}
+	}
+	cr.Status.History = append(cr.Status.History, entry)
+	if overflow := len(cr.Status.History) - maxReleaseHistory; overflow > 0 {
+		cr.Status.History = cr.Status.History[overflow:]
+	}
+}
+
+// appliedRevision identifies a chart version, its values and the post renderers applied to it
+func appliedRevision(version string, values map[string]interface{}, postRenderersHash string) string {
+	return appliedRevisionWithHash(version, valuesHash(values), postRenderersHash)
+}
+
+func appliedRevisionWithHash(version, valuesHash, postRenderersHash string) string {
+	if postRenderersHash == "" {
+		return fmt.Sprintf("%s/%s", version, valuesHash[:12])
+	}
+	return fmt.Sprintf("%s/%s/%s", version, valuesHash[:12], postRenderersHash[:12])
+}
+
+// specValuesHash is the hash of the values helm gets for the spec, the rendered ones when values are templated
+func specValuesHash(cr *v1alpha1.Release) string {
+	if cr.Spec.TemplateValues && cr.Status.RenderedValuesHash != "" {
+		return cr.Status.RenderedValuesHash
+	}
+	return valuesHash(cr.Spec.ValuesOverride.V)
+}
+
+// postRenderersHash is a stable hash of the post renderers of the spec, empty when there are none
+func postRenderersHash(cr *v1alpha1.Release) string {
+	if len(cr.Spec.PostRenderers) == 0 {
+		return ""
+	}
+	raw, _ := json.Marshal(cr.Spec.PostRenderers)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// valuesHash is a stable hash of helm values, encoding/json sorts map keys
+func valuesHash(values map[string]interface{}) string {
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	raw, _ := json.Marshal(values)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
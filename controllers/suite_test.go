@@ -17,7 +17,9 @@ limitations under the License.
 package controllers
 
 import (
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
 	"github.com/coveros/genoa/pkg/utils"
+	"io/ioutil"
 	"k8s.io/apimachinery/pkg/runtime"
 	"path/filepath"
 	controllerruntime "sigs.k8s.io/controller-runtime"
@@ -77,12 +79,18 @@ var _ = BeforeSuite(func(done Done) {
 	})
 	Expect(errCreatingNewMgr).NotTo(HaveOccurred())
 
+	chartCacheDir, err := ioutil.TempDir("", "genoa-chart-cache")
+	Expect(err).NotTo(HaveOccurred())
+	chartCache, err := v3.NewChartCache(chartCacheDir, 1<<30)
+	Expect(err).NotTo(HaveOccurred())
+
 	err = (&ReleaseReconciler{
-		Scheme:   testRuntimeScheme,
-		Client:   testMgr.GetClient(),
-		Cfg:      cfg,
-		Notifier: utils.NewNotifier(),
-		Log:      controllerruntime.Log.WithName("test")}).
+		Scheme:     testRuntimeScheme,
+		Client:     testMgr.GetClient(),
+		Cfg:        cfg,
+		Notifier:   utils.NewNotifier(),
+		ChartCache: chartCache,
+		Log:        controllerruntime.Log.WithName("test")}).
 		SetupWithManager(testMgr)
 	Expect(err).NotTo(HaveOccurred())
 
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// isSuspended is true when either spec.suspend or the suspend annotation is set
+func isSuspended(cr *v1alpha1.Release) bool {
+	return cr.Spec.Suspend || strings.ToLower(cr.GetAnnotations()[utils.SuspendAnnotation]) == "true"
+}
+
+// suspendAnnotationChanged lets annotation only toggles through the generation filter
+func suspendAnnotationChanged(metaOld, metaNew metav1.Object) bool {
+	return metaOld.GetAnnotations()[utils.SuspendAnnotation] != metaNew.GetAnnotations()[utils.SuspendAnnotation]
+}
+
+// markSuspended records whether reconciliation is suspended and notifies when that changes
+func (r *ReleaseReconciler) markSuspended(cr *v1alpha1.Release, suspended bool) {
+	wasSuspended := cr.Status.IsConditionTrue(v1alpha1.ConditionSuspended)
+	generation := cr.GetGeneration()
+	if suspended {
+		cr.Status.SetCondition(v1alpha1.ConditionSuspended, metav1.ConditionTrue, "Suspended", "reconciliation is suspended", generation)
+		cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionFalse, "Suspended", "", generation)
+	} else if cr.Status.GetCondition(v1alpha1.ConditionSuspended) != nil {
+		cr.Status.SetCondition(v1alpha1.ConditionSuspended, metav1.ConditionFalse, "Resumed", "", generation)
+	}
+	if suspended == wasSuspended {
+		return
+	}
+
+	reason := "Release reconciliation resumed :arrow_forward:"
+	if suspended {
+		reason = "Release reconciliation suspended :double_vertical_bar:"
+	}
+	r.Log.Info(fmt.Sprintf("%v/%v %v", cr.GetNamespace(), cr.GetName(), reason))
+	r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+		Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+		Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+		EventType: cNotifyLib.Warning,
+		Fields: map[string]string{
+			"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+			"Namespace": cr.GetNamespace(),
+			"Reason":    reason},
+	})
+}
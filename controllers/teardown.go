@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+)
+
+// listDependents returns the Releases that depend on cr
+func (r *ReleaseReconciler) listDependents(cr *v1alpha1.Release) ([]v1alpha1.Release, error) {
+	dependents := &v1alpha1.ReleaseList{}
+	if err := r.Client.List(context.TODO(), dependents,
+		client.MatchingFields{dependsOnIndexField: dependencyKey(cr.GetNamespace(), cr.GetName())}); err != nil {
+		return nil, err
+	}
+	var others []v1alpha1.Release
+	for _, dependent := range dependents.Items {
+		if dependent.GetNamespace() == cr.GetNamespace() && dependent.GetName() == cr.GetName() {
+			continue
+		}
+		others = append(others, dependent)
+	}
+	return others, nil
+}
+
+func cascadesDeletion(cr *v1alpha1.Release) bool {
+	return strings.ToLower(cr.GetAnnotations()[utils.DeletionPolicyAnnotation]) == utils.DeletionPolicyCascade
+}
+
+// blockDeletion keeps a Release that is being deleted installed while other Releases depend on it.
+// With the cascade deletion policy the dependents are deleted too, so a group of Releases is torn down in reverse dependency order.
+// Dependents in other namespaces are only deleted when their namespace allows Releases from this one, the same as for
+// installing into it, otherwise they block the deletion until someone allowed to removes them.
+// It returns true while the deletion is blocked.
+func (r *ReleaseReconciler) blockDeletion(cr *v1alpha1.Release) (bool, error) {
+	dependents, errListingDependents := r.listDependents(cr)
+	if errListingDependents != nil {
+		return false, errListingDependents
+	}
+	if len(dependents) == 0 {
+		return false, nil
+	}
+
+	var waitingFor []string
+	for i := range dependents {
+		dependent := &dependents[i]
+		waitingFor = append(waitingFor, dependencyKey(dependent.GetNamespace(), dependent.GetName()))
+		if !cascadesDeletion(cr) || dependent.GetDeletionTimestamp() != nil {
+			continue
+		}
+		allowed, errCheckingNamespace := r.namespaceAllows(dependent.GetNamespace(), cr.GetNamespace())
+		if errCheckingNamespace != nil {
+			return true, errCheckingNamespace
+		}
+		if !allowed {
+			r.Log.Info(fmt.Sprintf("%v/%v not deleting dependent %v/%v, its namespace does not allow releases from %v",
+				cr.GetNamespace(), cr.GetName(), dependent.GetNamespace(), dependent.GetName(), cr.GetNamespace()))
+			continue
+		}
+		// the dependents cascade as well so the whole group goes
+		annotations := dependent.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[utils.DeletionPolicyAnnotation] = utils.DeletionPolicyCascade
+		dependent.SetAnnotations(annotations)
+		if errUpdating := r.Client.Update(context.TODO(), dependent); errUpdating != nil && !apiErrors.IsNotFound(errUpdating) {
+			return true, errUpdating
+		}
+		r.Log.Info(fmt.Sprintf("%v/%v deleting dependent %v/%v", cr.GetNamespace(), cr.GetName(), dependent.GetNamespace(), dependent.GetName()))
+		if errDeleting := r.Client.Delete(context.TODO(), dependent); errDeleting != nil && !apiErrors.IsNotFound(errDeleting) {
+			return true, errDeleting
+		}
+	}
+
+	message := fmt.Sprintf("waiting for dependents %v to be deleted", strings.Join(waitingFor, ", "))
+	r.Log.Info(fmt.Sprintf("%v/%v %v", cr.GetNamespace(), cr.GetName(), message))
+	if condition := cr.Status.GetCondition(v1alpha1.ConditionReady); condition == nil || condition.Reason != "DeletionBlocked" {
+		r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+			Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+			Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+			EventType: cNotifyLib.Warning,
+			Fields: map[string]string{
+				"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+				"Namespace": cr.GetNamespace(),
+				"Reason":    fmt.Sprintf("Release deletion is blocked :no_entry: %v", message)},
+		})
+	}
+	generation := cr.GetGeneration()
+	cr.Status.SetCondition(v1alpha1.ConditionReady, metav1.ConditionFalse, "DeletionBlocked", message, generation)
+	cr.Status.SetCondition(v1alpha1.ConditionReconciling, metav1.ConditionTrue, "DeletionBlocked", message, generation)
+	return true, nil
+}
+
+// dependenciesOf maps a deleted Release to the Releases it depended on, so their blocked deletions continue
+func dependenciesOf(o handler.MapObject) []reconcile.Request {
+	cr, ok := o.Object.(*v1alpha1.Release)
+	if !ok {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, key := range dependencyKeys(cr) {
+		namespaceAndName := strings.SplitN(key, "/", 2)
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespaceAndName[0], Name: namespaceAndName[1]}})
+	}
+	return requests
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	v3 "github.com/coveros/genoa/pkg/helm/v3"
+	"github.com/coveros/genoa/pkg/utils"
+	cNotifyLib "github.com/coveros/notification-library"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// defaultTestTimeout matches the helm default for helm test
+const defaultTestTimeout = 5 * time.Minute
+
+func testsEnabled(cr *v1alpha1.Release) bool {
+	return cr.Spec.Test != nil && cr.Spec.Test.Enable && !cr.Spec.DryRun
+}
+
+// testsPassed reports whether the helm tests of the installed revision already passed
+func testsPassed(cr *v1alpha1.Release, releaseInfo *release.Release) bool {
+	return cr.Status.Tests != nil && cr.Status.Tests.Revision == releaseInfo.Version && cr.Status.Tests.Passed
+}
+
+// runTests runs the helm tests of the release, records the results and notifies about failures
+func (r *ReleaseReconciler) runTests(cr *v1alpha1.Release, actionConfig *v3.HelmV3, releaseInfo *release.Release) error {
+	timeout := defaultTestTimeout
+	if parsed, err := time.ParseDuration(cr.Spec.Test.Timeout); err == nil && parsed > 0 {
+		timeout = parsed
+	}
+	r.Log.Info(fmt.Sprintf("%v/%v running helm tests of revision %v", cr.GetNamespace(), cr.GetName(), releaseInfo.Version))
+	results, errTesting := actionConfig.TestRelease(helmReleaseName(cr), timeout)
+
+	cr.Status.Tests = &v1alpha1.ReleaseTestStatus{Revision: releaseInfo.Version, Passed: errTesting == nil}
+	for _, result := range results {
+		testResult := v1alpha1.ReleaseTestResult{Name: result.Name, Phase: result.Phase}
+		if !result.StartedAt.IsZero() {
+			startedAt := metav1.NewTime(result.StartedAt)
+			testResult.StartedAt = &startedAt
+		}
+		if !result.CompletedAt.IsZero() {
+			completedAt := metav1.NewTime(result.CompletedAt)
+			testResult.CompletedAt = &completedAt
+		}
+		cr.Status.Tests.Results = append(cr.Status.Tests.Results, testResult)
+	}
+	if errTesting == nil {
+		return nil
+	}
+
+	errTesting = fmt.Errorf("helm tests failed: %v", errTesting)
+	r.Notifier.SendMsg(cNotifyLib.NotifyTemplate{
+		Channel:   utils.GetChannelIDForNotification(cr.ObjectMeta),
+		Title:     fmt.Sprintf("%v/%v", cr.GetNamespace(), cr.GetName()),
+		EventType: cNotifyLib.Failure,
+		Fields: map[string]string{
+			"Chart":     cr.Spec.Chart + "-" + cr.Spec.Version,
+			"Namespace": cr.GetNamespace(),
+			"Reason":    fmt.Sprintf("Release failed its tests :test_tube: :bug: %v", errTesting)},
+	})
+	return errTesting
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"github.com/coveros/genoa/pkg/values"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"text/template"
+)
+
+// valuesTemplateData is what values templates can refer to
+type valuesTemplateData struct {
+	Cluster struct {
+		Name   string
+		Domain string
+	}
+	Release struct {
+		Name      string
+		Namespace string
+	}
+}
+
+// renderValues returns the values helm gets, the spec values with their templates rendered. The rendered values are
+// never stored in the spec: a status update decodes the Release again and would bring the templates back.
+func (r *ReleaseReconciler) renderValues(cr *v1alpha1.Release) (map[string]interface{}, error) {
+	if !cr.Spec.TemplateValues {
+		cr.Status.RenderedValuesHash = ""
+		return cr.Spec.ValuesOverride.V, nil
+	}
+
+	data := valuesTemplateData{}
+	data.Cluster.Name = r.ClusterName
+	data.Cluster.Domain = r.ClusterDomain
+	data.Release.Name = helmReleaseName(cr)
+	data.Release.Namespace = releaseNamespace(cr)
+	funcs := template.FuncMap{
+		"configMapKey": func(name, key string) (string, error) {
+			configMap := &v1.ConfigMap{}
+			if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: cr.GetNamespace(), Name: name}, configMap); err != nil {
+				return "", err
+			}
+			value, ok := configMap.Data[key]
+			if !ok {
+				return "", fmt.Errorf("configmap %v has no key %v", name, key)
+			}
+			return value, nil
+		},
+	}
+
+	rendered, errRendering := values.Render(cr.Spec.ValuesOverride.V, data, funcs)
+	if errRendering != nil {
+		cr.Status.SetCondition(v1alpha1.ConditionValuesRendered, metav1.ConditionFalse, "ValuesTemplateFailed", errRendering.Error(), cr.GetGeneration())
+		return nil, errRendering
+	}
+	cr.Status.RenderedValuesHash = valuesHash(rendered)
+	cr.Status.SetCondition(v1alpha1.ConditionValuesRendered, metav1.ConditionTrue, "ValuesRendered", "", cr.GetGeneration())
+	return rendered, nil
+}
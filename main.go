@@ -21,6 +21,7 @@ import (
 	v3 "github.com/coveros/genoa/pkg/helm/v3"
 	"github.com/coveros/genoa/pkg/utils"
 	"os"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -49,12 +50,26 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var customRepoConfigPath string
+	var chartCacheDir string
+	var chartCacheMaxBytes int64
+	var chartMirrorDir string
+	var prometheusAddress string
+	var clusterName string
+	var clusterDomain string
+	var trustedNamespaces string
 
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for release manager. "+
 			"Enabling this will ensure there is only one active release manager.")
 	flag.StringVar(&customRepoConfigPath, "custom-helm-repos-file", "", "Your own custom helm repo files")
+	flag.StringVar(&chartCacheDir, "chart-cache-dir", "chart-cache", "Directory where downloaded charts are cached")
+	flag.Int64Var(&chartCacheMaxBytes, "chart-cache-max-bytes", 1<<30, "Max size of the chart cache before least recently used charts are evicted")
+	flag.StringVar(&chartMirrorDir, "chart-mirror-dir", "", "Serve charts from an offline bundle in this directory instead of the remote helm repos")
+	flag.StringVar(&prometheusAddress, "prometheus-address", "", "Prometheus that canary releases are analysed with, e.g. http://prometheus.monitoring:9090")
+	flag.StringVar(&clusterName, "cluster-name", "", "Name of the cluster, available to values templates as {{ .Cluster.Name }}")
+	flag.StringVar(&clusterDomain, "cluster-domain", "cluster.local", "Domain of the cluster, available to values templates as {{ .Cluster.Domain }}")
+	flag.StringVar(&trustedNamespaces, "trusted-release-namespaces", "", "Comma separated namespaces whose Releases may install into any namespace")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -74,19 +89,44 @@ func main() {
 		os.Exit(1)
 	}
 
+	repoOptions := map[string]v3.RepoOptions{}
 	if customRepoConfigPath != "" {
 		if errAddingCustomRepos := v3.AddReposFromFile(customRepoConfigPath); errAddingCustomRepos != nil {
 			setupLog.Error(errAddingCustomRepos, "Failed to add custom helm repos")
 			os.Exit(1)
 		}
+		if repoOptions, err = v3.LoadRepoOptionsFromFile(customRepoConfigPath); err != nil {
+			setupLog.Error(err, "Failed to load custom helm repo options")
+			os.Exit(1)
+		}
+	}
+
+	chartCache, errCreatingChartCache := v3.NewChartCache(chartCacheDir, chartCacheMaxBytes)
+	if errCreatingChartCache != nil {
+		setupLog.Error(errCreatingChartCache, "Failed to create chart cache")
+		os.Exit(1)
+	}
+
+	var chartMirror *v3.ChartMirror
+	if chartMirrorDir != "" {
+		setupLog.Info("air gapped mode, serving charts from " + chartMirrorDir)
+		chartMirror = &v3.ChartMirror{Dir: chartMirrorDir}
 	}
 
 	releaseReconciler := &controllers.ReleaseReconciler{
-		Client:   mgr.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("release"),
-		Scheme:   mgr.GetScheme(),
-		Cfg:      mgr.GetConfig(),
-		Notifier: utils.NewNotifier(),
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("release"),
+		Scheme:      mgr.GetScheme(),
+		Cfg:         mgr.GetConfig(),
+		Notifier:    utils.NewNotifier(),
+		ChartCache:  chartCache,
+		ChartMirror: chartMirror,
+		RepoOptions: repoOptions,
+
+		PrometheusAddress: prometheusAddress,
+		ClusterName:       clusterName,
+		ClusterDomain:     clusterDomain,
+		TrustedNamespaces: splitNamespaces(trustedNamespaces),
 	}
 
 	if err = releaseReconciler.SetupWithManager(mgr); err != nil {
@@ -101,3 +141,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitNamespaces parses a comma separated list of namespaces
+func splitNamespaces(list string) []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(list, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
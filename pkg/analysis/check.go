@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Check is a query whose value has to stay within Min and Max, either bound may be empty
+type Check struct {
+	Name  string
+	Query string
+	Min   string
+	Max   string
+}
+
+// Result is the outcome of a single Check
+type Result struct {
+	Name    string
+	Value   float64
+	Passed  bool
+	Message string
+}
+
+// Querier evaluates a query to a single value
+type Querier interface {
+	Query(query string) (float64, error)
+}
+
+// Run evaluates every check, a query that fails or has no value, e.g. a ratio without traffic, counts as a failed check
+func Run(querier Querier, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		results = append(results, run(querier, check))
+	}
+	return results
+}
+
+// Passed reports whether every result passed
+func Passed(results []Result) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func run(querier Querier, check Check) Result {
+	result := Result{Name: check.Name}
+	value, errQuerying := querier.Query(check.Query)
+	if errQuerying != nil {
+		result.Message = errQuerying.Error()
+		return result
+	}
+	result.Value = value
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		// comparisons with NaN are always false, it would pass any bound
+		result.Message = fmt.Sprintf("query returned %v, there is no data to analyse", value)
+		return result
+	}
+
+	if check.Min != "" {
+		min, errParsing := strconv.ParseFloat(check.Min, 64)
+		if errParsing != nil {
+			result.Message = fmt.Sprintf("min %q is not a number", check.Min)
+			return result
+		}
+		if value < min {
+			result.Message = fmt.Sprintf("%v is below the min of %v", value, check.Min)
+			return result
+		}
+	}
+	if check.Max != "" {
+		max, errParsing := strconv.ParseFloat(check.Max, 64)
+		if errParsing != nil {
+			result.Message = fmt.Sprintf("max %q is not a number", check.Max)
+			return result
+		}
+		if value > max {
+			result.Message = fmt.Sprintf("%v is above the max of %v", value, check.Max)
+			return result
+		}
+	}
+	result.Passed = true
+	return result
+}
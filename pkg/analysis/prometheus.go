@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultQueryTimeout = 30 * time.Second
+
+// Prometheus runs instant queries against the Prometheus HTTP API
+type Prometheus struct {
+	Address string
+	Client  *http.Client
+}
+
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type vectorSample struct {
+	Value []interface{} `json:"value"`
+}
+
+// Query evaluates an instant query and returns its value, which must be a scalar or the first sample of a vector
+func (p *Prometheus) Query(query string) (float64, error) {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultQueryTimeout}
+	}
+	queryURL := strings.TrimSuffix(p.Address, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	resp, err := client.Get(queryURL)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	response := queryResponse{}
+	errDecoding := json.NewDecoder(resp.Body).Decode(&response)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if errDecoding == nil && response.Error != "" {
+			return 0, fmt.Errorf("prometheus returned %v for query %q: %v %v", resp.Status, query, response.ErrorType, response.Error)
+		}
+		return 0, fmt.Errorf("prometheus returned %v for query %q", resp.Status, query)
+	}
+	if errDecoding != nil {
+		return 0, fmt.Errorf("prometheus returned %v: %v", resp.Status, errDecoding)
+	}
+	if response.Status != "success" {
+		return 0, fmt.Errorf("prometheus query %q failed: %v %v", query, response.ErrorType, response.Error)
+	}
+
+	var value []interface{}
+	switch response.Data.ResultType {
+	case "scalar":
+		if errDecoding := json.Unmarshal(response.Data.Result, &value); errDecoding != nil {
+			return 0, errDecoding
+		}
+	case "vector":
+		var samples []vectorSample
+		if errDecoding := json.Unmarshal(response.Data.Result, &samples); errDecoding != nil {
+			return 0, errDecoding
+		}
+		if len(samples) == 0 {
+			return 0, fmt.Errorf("prometheus query %q returned no data", query)
+		}
+		value = samples[0].Value
+	default:
+		return 0, fmt.Errorf("prometheus query %q returned a %v, expected a scalar or vector", query, response.Data.ResultType)
+	}
+	return parseSampleValue(value)
+}
+
+// parseSampleValue reads a [<timestamp>, "<value>"] pair
+func parseSampleValue(value []interface{}) (float64, error) {
+	if len(value) != 2 {
+		return 0, fmt.Errorf("unexpected sample %v", value)
+	}
+	s, ok := value[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected sample value %v", value[1])
+	}
+	return strconv.ParseFloat(s, 64)
+}
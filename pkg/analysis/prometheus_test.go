@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrometheus_Query(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		status   int
+		want     float64
+		wantErr  bool
+	}{
+		{name: "vector", response: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1600000000.1,"0.25"]}]}}`, want: 0.25},
+		{name: "scalar", response: `{"status":"success","data":{"resultType":"scalar","result":[1600000000.1,"42"]}}`, want: 42},
+		{name: "empty vector", response: `{"status":"success","data":{"resultType":"vector","result":[]}}`, wantErr: true},
+		{name: "matrix", response: `{"status":"success","data":{"resultType":"matrix","result":[]}}`, wantErr: true},
+		{name: "query error", response: `{"status":"error","errorType":"bad_data","error":"parse error"}`, wantErr: true},
+		{name: "not json", response: `<html></html>`, wantErr: true},
+		{name: "unavailable", response: `<html></html>`, status: http.StatusServiceUnavailable, wantErr: true},
+		{name: "bad request", response: `{"status":"error","errorType":"bad_data","error":"parse error"}`, status: http.StatusBadRequest, wantErr: true},
+		{name: "success body with error status", response: `{"status":"success","data":{"resultType":"scalar","result":[1600000000.1,"42"]}}`, status: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotQuery string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotQuery = r.URL.Query().Get("query")
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			p := &Prometheus{Address: server.URL + "/"}
+			got, err := p.Query(`sum(rate(http_requests_total{code=~"5.."}[1m]))`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Query() got = %v, want %v", got, tt.want)
+			}
+			if gotQuery != `sum(rate(http_requests_total{code=~"5.."}[1m]))` {
+				t.Errorf("Query() sent query %q", gotQuery)
+			}
+		})
+	}
+}
+
+type fakeQuerier map[string]float64
+
+func (f fakeQuerier) Query(query string) (float64, error) {
+	value, ok := f[query]
+	if !ok {
+		return 0, errNoData
+	}
+	return value, nil
+}
+
+var errNoData = errors.New("no data")
+
+func TestRun(t *testing.T) {
+	querier := fakeQuerier{"errors": 0.02, "latency": 250, "no traffic": math.NaN(), "infinite": math.Inf(1)}
+	tests := []struct {
+		name  string
+		check Check
+		want  bool
+	}{
+		{name: "within max", check: Check{Query: "errors", Max: "0.05"}, want: true},
+		{name: "above max", check: Check{Query: "errors", Max: "0.01"}},
+		{name: "within range", check: Check{Query: "latency", Min: "0", Max: "300"}, want: true},
+		{name: "below min", check: Check{Query: "latency", Min: "300"}},
+		{name: "no bounds", check: Check{Query: "latency"}, want: true},
+		{name: "query fails", check: Check{Query: "missing", Max: "1"}},
+		{name: "bad bound", check: Check{Query: "errors", Max: "five"}},
+		{name: "NaN without traffic", check: Check{Query: "no traffic", Max: "0.01"}},
+		{name: "NaN without bounds", check: Check{Query: "no traffic"}},
+		{name: "infinite", check: Check{Query: "infinite", Min: "0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Run(querier, []Check{tt.check})
+			if got := Passed(results); got != tt.want {
+				t.Errorf("Passed() = %v, want %v, results %+v", got, tt.want, results)
+			}
+		})
+	}
+}
@@ -31,3 +31,67 @@ type ErrorChartEntryNotFoundInRepoIndex struct {
 func (e ErrorChartEntryNotFoundInRepoIndex) Error() string {
 	return e.Message
 }
+
+type ErrorChartDigestMismatch struct {
+	Message string
+}
+
+func (e ErrorChartDigestMismatch) Error() string {
+	return e.Message
+}
+
+type ErrorChartVerificationFailed struct {
+	Message string
+}
+
+func (e ErrorChartVerificationFailed) Error() string {
+	return e.Message
+}
+
+type ErrorRepoAuthFailed struct {
+	Message string
+}
+
+func (e ErrorRepoAuthFailed) Error() string {
+	return e.Message
+}
+
+type ErrorRepoNotFound struct {
+	Message string
+}
+
+func (e ErrorRepoNotFound) Error() string {
+	return e.Message
+}
+
+type ErrorRepoServerError struct {
+	Message string
+}
+
+func (e ErrorRepoServerError) Error() string {
+	return e.Message
+}
+
+type ErrorRepoTimeout struct {
+	Message string
+}
+
+func (e ErrorRepoTimeout) Error() string {
+	return e.Message
+}
+
+type ErrorRepoUnexpectedStatus struct {
+	Message string
+}
+
+func (e ErrorRepoUnexpectedStatus) Error() string {
+	return e.Message
+}
+
+type ErrorInvalidChartReference struct {
+	Message string
+}
+
+func (e ErrorInvalidChartReference) Error() string {
+	return e.Message
+}
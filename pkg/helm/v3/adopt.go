@@ -0,0 +1,32 @@
+package v3
+
+import (
+	"fmt"
+	"github.com/coveros/genoa/api/v1alpha1"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReleaseManifest describes an existing helm release as a Release that adopts it. Helm does not record the repo a chart
+// came from, so repoAlias names it. The Release lives in the namespace of the helm release unless namespace is set,
+// then it installs into the helm release namespace with targetNamespace.
+func ReleaseManifest(releaseInfo *release.Release, repoAlias, namespace string) (*v1alpha1.Release, error) {
+	if releaseInfo.Chart == nil || releaseInfo.Chart.Metadata == nil {
+		return nil, fmt.Errorf("helm release %s/%s has no chart metadata", releaseInfo.Namespace, releaseInfo.Name)
+	}
+	manifest := &v1alpha1.Release{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "Release"},
+		ObjectMeta: metav1.ObjectMeta{Name: releaseInfo.Name, Namespace: releaseInfo.Namespace},
+		Spec: v1alpha1.ReleaseSpec{
+			Chart:   repoAlias + "/" + releaseInfo.Chart.Metadata.Name,
+			Version: releaseInfo.Chart.Metadata.Version,
+			Adopt:   true,
+		},
+	}
+	manifest.Spec.ValuesOverride.V = releaseInfo.Config
+	if namespace != "" && namespace != releaseInfo.Namespace {
+		manifest.Namespace = namespace
+		manifest.Spec.TargetNamespace = releaseInfo.Namespace
+	}
+	return manifest, nil
+}
@@ -0,0 +1,52 @@
+package v3
+
+import (
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"reflect"
+	"testing"
+)
+
+func TestReleaseManifest(t *testing.T) {
+	releaseInfo := &release.Release{
+		Name:      "jenkins",
+		Namespace: "ci",
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "jenkins", Version: "2.4.1"}},
+		Config:    map[string]interface{}{"master": map[string]interface{}{"adminUser": "admin"}},
+	}
+	tests := []struct {
+		name                string
+		namespace           string
+		wantNamespace       string
+		wantTargetNamespace string
+	}{
+		{name: "namespace of the helm release", wantNamespace: "ci"},
+		{name: "same namespace", namespace: "ci", wantNamespace: "ci"},
+		{name: "central namespace", namespace: "genoa-releases", wantNamespace: "genoa-releases", wantTargetNamespace: "ci"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReleaseManifest(releaseInfo, "stable", tt.namespace)
+			if err != nil {
+				t.Fatalf("ReleaseManifest() error = %v", err)
+			}
+			if got.Kind != "Release" || got.APIVersion != "coveros.apps.com/v1alpha1" {
+				t.Errorf("ReleaseManifest() type = %v %v", got.APIVersion, got.Kind)
+			}
+			if got.Name != "jenkins" || got.Namespace != tt.wantNamespace || got.Spec.TargetNamespace != tt.wantTargetNamespace {
+				t.Errorf("ReleaseManifest() = %v/%v targeting %q, want %v/jenkins targeting %q",
+					got.Namespace, got.Name, got.Spec.TargetNamespace, tt.wantNamespace, tt.wantTargetNamespace)
+			}
+			if got.Spec.Chart != "stable/jenkins" || got.Spec.Version != "2.4.1" || !got.Spec.Adopt {
+				t.Errorf("ReleaseManifest() spec = %v %v adopt %v", got.Spec.Chart, got.Spec.Version, got.Spec.Adopt)
+			}
+			if !reflect.DeepEqual(got.Spec.ValuesOverride.V, releaseInfo.Config) {
+				t.Errorf("ReleaseManifest() values = %v, want %v", got.Spec.ValuesOverride.V, releaseInfo.Config)
+			}
+		})
+	}
+
+	if _, err := ReleaseManifest(&release.Release{Name: "broken"}, "stable", ""); err == nil {
+		t.Errorf("ReleaseManifest() without chart metadata, want error")
+	}
+}
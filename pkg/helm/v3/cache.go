@@ -0,0 +1,253 @@
+package v3
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/coveros/genoa/pkg"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChartCacheKey identifies a chart tarball within the ChartCache
+type ChartCacheKey struct {
+	Repo    string
+	Chart   string
+	Version string
+	Digest  string
+}
+
+// fileName is the path of the chart within the cache dir. Parts that could point outside of it, e.g. a version
+// of "../../x", are rejected.
+func (k ChartCacheKey) fileName() (string, error) {
+	digest := k.Digest
+	if digest == "" {
+		digest = "unverified"
+	}
+	for _, part := range []string{k.Repo, strings.ReplaceAll(k.Chart, "/", "-"), k.Version, digest} {
+		if part == "" || strings.ContainsAny(part, `/\`) || strings.Contains(part, "..") {
+			return "", pkg.ErrorInvalidChartReference{Message: fmt.Sprintf("%s/%s %s is not a valid chart reference", k.Repo, k.Chart, k.Version)}
+		}
+	}
+	// keep the tarball name helm packaged it with, provenance files refer to it by that name
+	chartTarballName := fmt.Sprintf("%s-%s.tgz", strings.ReplaceAll(k.Chart, "/", "-"), k.Version)
+	return filepath.Join(strings.ToLower(k.Repo), digest, chartTarballName), nil
+}
+
+// ChartCache is a bounded on-disk cache of chart tarballs that is shared across reconciles.
+// Entries are content addressed by repo/chart/version/digest and evicted least recently used first,
+// charts that are currently handed out to a reconcile are never evicted.
+type ChartCache struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	size     int64
+	lru      *list.List // front is the most recently used entry
+	entries  map[string]*list.Element
+	inflight map[string]*chartDownload
+}
+
+type chartCacheEntry struct {
+	name string
+	size int64
+	refs int
+}
+
+type chartDownload struct {
+	done chan struct{}
+	err  error
+}
+
+// NewChartCache creates a chart cache rooted at dir, picking up any tarballs left behind by a previous run
+func NewChartCache(dir string, maxBytes int64) (*ChartCache, error) {
+	if errMakingDir := os.MkdirAll(dir, 0755); errMakingDir != nil {
+		return nil, errMakingDir
+	}
+
+	c := &ChartCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+		inflight: map[string]*chartDownload{},
+	}
+
+	var existing []*chartCacheEntry
+	modTimes := map[string]time.Time{}
+	errWalking := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		// partial downloads from a previous run are never valid
+		if strings.HasSuffix(path, ".tmp") {
+			return os.Remove(path)
+		}
+		if !strings.HasSuffix(path, ".tgz") {
+			return nil
+		}
+		name, errRel := filepath.Rel(dir, path)
+		if errRel != nil {
+			return errRel
+		}
+		existing = append(existing, &chartCacheEntry{name: name, size: info.Size()})
+		modTimes[name] = info.ModTime()
+		return nil
+	})
+	if errWalking != nil {
+		return nil, errWalking
+	}
+
+	// oldest first, so the most recently written chart ends up at the front
+	sort.Slice(existing, func(i, j int) bool {
+		return modTimes[existing[i].name].Before(modTimes[existing[j].name])
+	})
+	for _, entry := range existing {
+		c.entries[entry.name] = c.lru.PushFront(entry)
+		c.size += entry.size
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	return c, nil
+}
+
+// Get returns the local path of the chart identified by key, calling download to fetch it into the cache
+// when it is not present yet. Concurrent calls for the same key share a single download.
+// The returned func must be called once the caller no longer needs the chart.
+func (c *ChartCache) Get(key ChartCacheKey, download func(dest string) error) (string, func(), error) {
+	name, errNaming := key.fileName()
+	if errNaming != nil {
+		return "", nil, errNaming
+	}
+	for {
+		c.mu.Lock()
+		if elem, ok := c.entries[name]; ok {
+			entry := elem.Value.(*chartCacheEntry)
+			entry.refs++
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return filepath.Join(c.dir, name), c.releaseFunc(entry), nil
+		}
+
+		if inflight, ok := c.inflight[name]; ok {
+			c.mu.Unlock()
+			<-inflight.done
+			if inflight.err != nil {
+				return "", nil, inflight.err
+			}
+			continue
+		}
+
+		dl := &chartDownload{done: make(chan struct{})}
+		c.inflight[name] = dl
+		c.mu.Unlock()
+
+		size, errFetching := c.fetch(name, key.Digest, download)
+
+		c.mu.Lock()
+		delete(c.inflight, name)
+		dl.err = errFetching
+		close(dl.done)
+		if errFetching != nil {
+			c.mu.Unlock()
+			return "", nil, errFetching
+		}
+		entry := &chartCacheEntry{name: name, size: size, refs: 1}
+		c.entries[name] = c.lru.PushFront(entry)
+		c.size += size
+		c.evict()
+		c.mu.Unlock()
+		return filepath.Join(c.dir, name), c.releaseFunc(entry), nil
+	}
+}
+
+func (c *ChartCache) releaseFunc(entry *chartCacheEntry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			entry.refs--
+			c.evict()
+		})
+	}
+}
+
+// fetch downloads into a temp file next to the final location, verifies the digest and then moves it into place
+func (c *ChartCache) fetch(name, digest string, download func(dest string) error) (int64, error) {
+	dest := filepath.Join(c.dir, name)
+	if errMakingDir := os.MkdirAll(filepath.Dir(dest), 0755); errMakingDir != nil {
+		return 0, errMakingDir
+	}
+
+	tmpFile, errCreatingTmp := ioutil.TempFile(filepath.Dir(dest), filepath.Base(dest)+".*.tmp")
+	if errCreatingTmp != nil {
+		return 0, errCreatingTmp
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	defer os.Remove(tmpPath)
+
+	if errDownloading := download(tmpPath); errDownloading != nil {
+		return 0, errDownloading
+	}
+
+	gotDigest, size, errHashing := sha256File(tmpPath)
+	if errHashing != nil {
+		return 0, errHashing
+	}
+	if digest != "" && !strings.EqualFold(gotDigest, digest) {
+		return 0, pkg.ErrorChartDigestMismatch{Message: fmt.Sprintf("%v digest mismatch, repo index has %v but downloaded chart has %v", name, digest, gotDigest)}
+	}
+
+	if errRenaming := os.Rename(tmpPath, dest); errRenaming != nil {
+		return 0, errRenaming
+	}
+	helmInfoLogF("cached chart %v (%v bytes)", name, size)
+	return size, nil
+}
+
+// evict drops least recently used charts that are not in use until the cache fits in maxBytes, c.mu must be held
+func (c *ChartCache) evict() {
+	for elem := c.lru.Back(); elem != nil && c.size > c.maxBytes; {
+		prev := elem.Prev()
+		entry := elem.Value.(*chartCacheEntry)
+		if entry.refs == 0 {
+			if errRemoving := os.Remove(filepath.Join(c.dir, entry.name)); errRemoving != nil && !os.IsNotExist(errRemoving) {
+				logger.Error(errRemoving, fmt.Sprintf("Failed to evict %v from chart cache", entry.name))
+			} else {
+				// drop the digest dir as well, this is a no-op if it is not empty
+				_ = os.Remove(filepath.Dir(filepath.Join(c.dir, entry.name)))
+				helmInfoLogF("evicted chart %v from cache", entry.name)
+			}
+			c.lru.Remove(elem)
+			delete(c.entries, entry.name)
+			c.size -= entry.size
+		}
+		elem = prev
+	}
+}
+
+func sha256File(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
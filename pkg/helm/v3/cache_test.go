@@ -0,0 +1,181 @@
+package v3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/coveros/genoa/pkg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func writeContent(content string) func(dest string) error {
+	return func(dest string) error {
+		return ioutil.WriteFile(dest, []byte(content), 0644)
+	}
+}
+
+func digestOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestChartCache_Get(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     ChartCacheKey
+		content string
+		wantErr error
+	}{
+		{
+			name:    "digest matches",
+			key:     ChartCacheKey{Repo: "stable", Chart: "jenkins", Version: "2.4.1", Digest: digestOf("jenkins")},
+			content: "jenkins",
+		},
+		{
+			name:    "no digest in index",
+			key:     ChartCacheKey{Repo: "stable", Chart: "jenkins", Version: "2.4.1"},
+			content: "jenkins",
+		},
+		{
+			name:    "digest mismatch",
+			key:     ChartCacheKey{Repo: "stable", Chart: "jenkins", Version: "2.4.1", Digest: digestOf("not-jenkins")},
+			content: "jenkins",
+			wantErr: pkg.ErrorChartDigestMismatch{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := ioutil.TempDir("", "chart-cache")
+			defer os.RemoveAll(dir)
+			cache, err := NewChartCache(dir, 1<<20)
+			if err != nil {
+				t.Fatalf("NewChartCache() error = %v", err)
+			}
+
+			got, release, err := cache.Get(tt.key, writeContent(tt.content))
+			if tt.wantErr != nil {
+				if _, ok := err.(pkg.ErrorChartDigestMismatch); !ok {
+					t.Errorf("Get() error = %v, wantErr %T", err, tt.wantErr)
+				}
+				name, _ := tt.key.fileName()
+				if _, errStat := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(errStat) {
+					t.Errorf("Get() left an unverified chart in the cache")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer release()
+			if b, _ := ioutil.ReadFile(got); string(b) != tt.content {
+				t.Errorf("Get() content = %v, want %v", string(b), tt.content)
+			}
+		})
+	}
+}
+
+func TestChartCache_GetInvalidKey(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "chart-cache")
+	defer os.RemoveAll(dir)
+	cache, err := NewChartCache(filepath.Join(dir, "cache"), 1<<20)
+	if err != nil {
+		t.Fatalf("NewChartCache() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  ChartCacheKey
+	}{
+		{name: "version climbs out of the cache", key: ChartCacheKey{Repo: "stable", Chart: "jenkins", Version: "../../../x"}},
+		{name: "version with a separator", key: ChartCacheKey{Repo: "stable", Chart: "jenkins", Version: "1.0.0/x"}},
+		{name: "chart climbs out of the cache", key: ChartCacheKey{Repo: "stable", Chart: "..", Version: "1.0.0"}},
+		{name: "repo climbs out of the cache", key: ChartCacheKey{Repo: "../..", Chart: "jenkins", Version: "1.0.0"}},
+		{name: "backslash", key: ChartCacheKey{Repo: "stable", Chart: "jenkins", Version: `1.0.0\x`}},
+		{name: "empty version", key: ChartCacheKey{Repo: "stable", Chart: "jenkins"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloaded := false
+			_, _, err := cache.Get(tt.key, func(dest string) error {
+				downloaded = true
+				return ioutil.WriteFile(dest, []byte("jenkins"), 0644)
+			})
+			if _, ok := err.(pkg.ErrorInvalidChartReference); !ok {
+				t.Errorf("Get() error = %v, want ErrorInvalidChartReference", err)
+			}
+			if downloaded {
+				t.Errorf("Get() downloaded a chart with an invalid key")
+			}
+		})
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Get() wrote outside of the cache dir: %v", entries)
+	}
+}
+
+func TestChartCache_Evict(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "chart-cache")
+	defer os.RemoveAll(dir)
+	// room for two 4 byte charts
+	cache, err := NewChartCache(dir, 8)
+	if err != nil {
+		t.Fatalf("NewChartCache() error = %v", err)
+	}
+
+	a := ChartCacheKey{Repo: "stable", Chart: "a", Version: "1.0.0"}
+	b := ChartCacheKey{Repo: "stable", Chart: "b", Version: "1.0.0"}
+	c := ChartCacheKey{Repo: "stable", Chart: "c", Version: "1.0.0"}
+
+	pathA, releaseA, _ := cache.Get(a, writeContent("aaaa"))
+	pathB, releaseB, _ := cache.Get(b, writeContent("bbbb"))
+	releaseB()
+	// a is still in use, so b is the only candidate for eviction
+	pathC, releaseC, _ := cache.Get(c, writeContent("cccc"))
+	defer releaseC()
+
+	for _, want := range []struct {
+		path   string
+		exists bool
+	}{{pathA, true}, {pathB, false}, {pathC, true}} {
+		if _, errStat := os.Stat(want.path); os.IsNotExist(errStat) == want.exists {
+			t.Errorf("%v exists = %v, want %v", want.path, !want.exists, want.exists)
+		}
+	}
+	releaseA()
+}
+
+func TestChartCache_ConcurrentGet(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "chart-cache")
+	defer os.RemoveAll(dir)
+	cache, err := NewChartCache(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("NewChartCache() error = %v", err)
+	}
+
+	var downloads int32
+	key := ChartCacheKey{Repo: "stable", Chart: "jenkins", Version: "2.4.1", Digest: digestOf("jenkins")}
+	var wg sync.WaitGroup
+	for i := 0; i < 7; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, release, err := cache.Get(key, func(dest string) error {
+				atomic.AddInt32(&downloads, 1)
+				return writeContent("jenkins")(dest)
+			})
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+				return
+			}
+			release()
+		}()
+	}
+	wg.Wait()
+	if downloads != 1 {
+		t.Errorf("chart downloaded %v times, want 1", downloads)
+	}
+}
@@ -10,49 +10,68 @@ import (
 	"strings"
 )
 
-func (h HelmV3) DownloadChart(repoUrl, repoAlias, chart, version, username, password, destDir string) (string, error) {
+// PullChart returns a local path to the chart tarball, downloading it into the chart cache if needed.
+// When keyrings are given the chart must also have a valid provenance file signed by a key in each of them.
+// The returned func must be called once the chart is no longer needed so the cache is free to evict it.
+func (h HelmV3) PullChart(cache *ChartCache, repoEntry *repo.Entry, repoOpts RepoOptions, chart, version string, keyrings [][]byte) (string, func(), error) {
+	repoAlias := repoEntry.Name
+	chartVersion, errFindingChart := h.getChartVersionFromRepoIndex(repoAlias, chart, version)
+	if errFindingChart != nil {
+		return "", nil, errFindingChart
+	}
 
-	_, err := os.Stat(destDir)
-	if os.IsNotExist(err) {
-		if errMakingDir := os.MkdirAll(destDir, os.ModeDir); errMakingDir != nil {
-			helmInfoLogF("Failed to create dir to download chart into: %v", errMakingDir)
-			return "", errMakingDir
-		}
+	client, errCreatingClient := newRepoHTTPClient(repoEntry, repoOpts)
+	if errCreatingClient != nil {
+		return "", nil, errCreatingClient
 	}
-	// first attempt to assume a download url, so we dont have to look up url in index file
-	chartTarballName := fmt.Sprintf("%s-%s.tgz", strings.ReplaceAll(chart, "/", "-"), version)
-	assumedChartPath := fmt.Sprintf("%s/%s", destDir, chartTarballName)
-	assumedDownloadUrl := fmt.Sprintf("%s/%s", repoUrl, chartTarballName)
-	if errDownloadingChart := utils.DownloadFile(assumedChartPath, assumedDownloadUrl, username, password); errDownloadingChart != nil {
-
-		assumedIndexFileName := repoAlias + "-index.yaml"
-		indexFile := filepath.Join(h.settings.RepositoryCache, assumedIndexFileName)
-		// if repo cache file not found, throw an error that indicates to download repo index.
-		if _, err := os.Stat(indexFile); os.IsNotExist(err) {
-			return "", pkg.ErrorHelmRepoNeedsRefresh{Message: fmt.Sprintf("%s repo index file not found, a refresh can help", repoAlias)}
-		}
 
-		// load index file in memory
-		repoIndexFile, errLoadingIndex := repo.LoadIndexFile(indexFile)
-		if errLoadingIndex != nil {
-			logger.Error(errLoadingIndex, "Could not load index file")
-			return assumedChartPath, errLoadingIndex
-		}
+	downloadUrl := chartVersion.URLs[0]
+	// some download urls are not really urls, so fix that ( based on different registry implementation
+	if !strings.HasPrefix(downloadUrl, "https://") && !strings.HasPrefix(downloadUrl, "http://") {
+		downloadUrl = fmt.Sprintf("%s/%s", utils.TrimSuffix(repoEntry.URL, "/"), downloadUrl)
+	}
 
-		// attempt to find the chart version from repo index file
-		downloadUrl, errGettingDownloadUrl := h.FindDownloadUrlFromCacheFile(repoIndexFile, chart, version)
-		if errGettingDownloadUrl != nil {
-			logger.Error(errGettingDownloadUrl, "Could not find a download url")
-			return assumedChartPath, errGettingDownloadUrl
-		}
+	cacheKey := ChartCacheKey{Repo: repoAlias, Chart: chart, Version: version, Digest: chartVersion.Digest}
+	chartPath, releaseChart, errGettingChart := cache.Get(cacheKey, func(dest string) error {
+		logger.Info(fmt.Sprintf("attempting to download chart from index url %s", downloadUrl))
+		return utils.DownloadFile(client, dest, downloadUrl, repoEntry.Username, repoEntry.Password)
+	})
+	if errGettingChart != nil {
+		return "", nil, errGettingChart
+	}
 
-		// some download urls are not really urls, so fix that ( based on different registry implementation
-		if !strings.HasPrefix(downloadUrl, "https://") {
-			downloadUrl = fmt.Sprintf("%s/%s", utils.TrimSuffix(repoUrl, "/"), downloadUrl)
+	if len(keyrings) > 0 {
+		if errVerifying := verifyChart(client, chartPath, downloadUrl, repoEntry.Username, repoEntry.Password, keyrings); errVerifying != nil {
+			releaseChart()
+			return "", nil, errVerifying
 		}
-		logger.Info(fmt.Sprintf("attempting to download chart from index url %s", downloadUrl))
+	}
+	return chartPath, releaseChart, nil
+}
+
+func (h HelmV3) getChartVersionFromRepoIndex(repoAlias, chart, version string) (*repo.ChartVersion, error) {
+	assumedIndexFileName := repoAlias + "-index.yaml"
+	indexFile := filepath.Join(h.settings.RepositoryCache, assumedIndexFileName)
+	// if repo cache file not found, throw an error that indicates to download repo index.
+	if _, err := os.Stat(indexFile); os.IsNotExist(err) {
+		return nil, pkg.ErrorHelmRepoNeedsRefresh{Message: fmt.Sprintf("%s repo index file not found, a refresh can help", repoAlias)}
+	}
+
+	// load index file in memory
+	repoIndexFile, errLoadingIndex := repo.LoadIndexFile(indexFile)
+	if errLoadingIndex != nil {
+		logger.Error(errLoadingIndex, "Could not load index file")
+		return nil, errLoadingIndex
+	}
 
-		return assumedChartPath, utils.DownloadFile(assumedChartPath, downloadUrl, username, password)
+	// attempt to find the chart version from repo index file
+	chartVersion, errGettingChartVersion := findChartVersionFromCacheFile(repoIndexFile, chart, version)
+	if errGettingChartVersion != nil {
+		logger.Error(errGettingChartVersion, "Could not find chart version in repo index")
+		return nil, errGettingChartVersion
+	}
+	if len(chartVersion.URLs) == 0 {
+		return nil, pkg.ErrorInvalidChartDownloadUrl{Message: fmt.Sprintf("%v-%v has no download url in %v repo index", chart, version, repoAlias)}
 	}
-	return assumedChartPath, nil
+	return chartVersion, nil
 }
@@ -0,0 +1,232 @@
+package v3
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sort"
+	"strings"
+)
+
+const (
+	// diffContext is how many unchanged lines are shown around a change
+	diffContext = 3
+	// maxDiffCells bounds the lines compared line by line, larger changes are shown as a whole block removed and added
+	maxDiffCells = 1 << 20
+)
+
+// ManifestDiff is the difference between the manifests of two helm revisions, object by object
+type ManifestDiff struct {
+	Added   int
+	Changed int
+	Removed int
+	// Diff is a unified diff of every object that is added, changed or removed
+	Diff string
+}
+
+func (d ManifestDiff) String() string {
+	return fmt.Sprintf("%d to add, %d to change, %d to remove", d.Added, d.Changed, d.Removed)
+}
+
+// DiffManifests compares two rendered manifests, either may be empty. The values of Secrets are redacted, a changed
+// value still shows up as a changed line but the diff never holds the value itself.
+func DiffManifests(current, proposed string) (ManifestDiff, error) {
+	// a key of its own for every diff, so the redacted values cannot be compared with anything outside of it
+	redactionKey := make([]byte, 32)
+	if _, errGeneratingKey := rand.Read(redactionKey); errGeneratingKey != nil {
+		return ManifestDiff{}, errGeneratingKey
+	}
+	currentObjects, errParsing := manifestsByObject(current, redactionKey)
+	if errParsing != nil {
+		return ManifestDiff{}, errParsing
+	}
+	proposedObjects, errParsing := manifestsByObject(proposed, redactionKey)
+	if errParsing != nil {
+		return ManifestDiff{}, errParsing
+	}
+
+	keys := make([]string, 0, len(currentObjects)+len(proposedObjects))
+	for key := range currentObjects {
+		keys = append(keys, key)
+	}
+	for key := range proposedObjects {
+		if _, ok := currentObjects[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	diff := ManifestDiff{}
+	var out strings.Builder
+	for _, key := range keys {
+		currentManifest, inCurrent := currentObjects[key]
+		proposedManifest, inProposed := proposedObjects[key]
+		switch {
+		case !inCurrent:
+			diff.Added++
+		case !inProposed:
+			diff.Removed++
+		case currentManifest == proposedManifest:
+			continue
+		default:
+			diff.Changed++
+		}
+		fmt.Fprintf(&out, "--- %s\n+++ %s\n", key, key)
+		out.WriteString(diffLines(splitLines(currentManifest), splitLines(proposedManifest)))
+	}
+	diff.Diff = out.String()
+	return diff, nil
+}
+
+// manifestsByObject normalises every object of a manifest to sorted YAML, keyed by kind, namespace and name
+func manifestsByObject(manifest string, redactionKey []byte) (map[string]string, error) {
+	objects, errParsing := parseManifests(manifest)
+	if errParsing != nil {
+		return nil, errParsing
+	}
+	byObject := make(map[string]string, len(objects))
+	for _, obj := range objects {
+		if obj.GetKind() == "Secret" && obj.GroupVersionKind().Group == "" {
+			redactSecret(obj, redactionKey)
+		}
+		normalised, errMarshaling := yaml.Marshal(obj.Object)
+		if errMarshaling != nil {
+			return nil, errMarshaling
+		}
+		key := fmt.Sprintf("%s %s", obj.GetKind(), obj.GetName())
+		if obj.GetNamespace() != "" {
+			key = fmt.Sprintf("%s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		}
+		byObject[key] = string(normalised)
+	}
+	return byObject, nil
+}
+
+// redactSecret replaces the data and stringData values of a Secret with a keyed hash of the value
+func redactSecret(secret *unstructured.Unstructured, key []byte) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := secret.Object[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, value := range values {
+			mac := hmac.New(sha256.New, key)
+			fmt.Fprint(mac, value)
+			values[name] = "(redacted " + hex.EncodeToString(mac.Sum(nil))[:12] + ")"
+		}
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// diffLines returns the changed lines prefixed with - and +, with diffContext unchanged lines around them
+func diffLines(from, to []string) string {
+	// the unchanged start and end of an object need no table
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	var lines []diffLine
+	for _, text := range from[:prefix] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	lines = append(lines, diffMiddle(from[prefix:len(from)-suffix], to[prefix:len(to)-suffix])...)
+	for _, text := range from[len(from)-suffix:] {
+		lines = append(lines, diffLine{' ', text})
+	}
+
+	// keep unchanged lines only near a change
+	keep := make([]bool, len(lines))
+	for n, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for k := n - diffContext; k <= n+diffContext; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+	var out strings.Builder
+	skipped := false
+	for n, l := range lines {
+		if !keep[n] {
+			skipped = true
+			continue
+		}
+		if skipped && out.Len() > 0 {
+			out.WriteString("@@\n")
+		}
+		skipped = false
+		fmt.Fprintf(&out, "%c%s\n", l.op, l.text)
+	}
+	return out.String()
+}
+
+// diffMiddle diffs lines with a longest common subsequence table. Changes too large for the table are shown as
+// every line removed and added, so memory stays bounded for objects like large dashboard ConfigMaps.
+func diffMiddle(from, to []string) []diffLine {
+	var lines []diffLine
+	if len(from)*len(to) > maxDiffCells {
+		for _, text := range from {
+			lines = append(lines, diffLine{'-', text})
+		}
+		for _, text := range to {
+			lines = append(lines, diffLine{'+', text})
+		}
+		return lines
+	}
+
+	// longest common subsequence, lcs[i][j] is the length for from[i:] and to[j:]
+	lcs := make([][]int32, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && from[i] == to[j]:
+			lines = append(lines, diffLine{' ', from[i]})
+			i++
+			j++
+		case j < len(to) && (i == len(from) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, diffLine{'+', to[j]})
+			j++
+		default:
+			lines = append(lines, diffLine{'-', from[i]})
+			i++
+		}
+	}
+	return lines
+}
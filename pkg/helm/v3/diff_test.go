@@ -0,0 +1,176 @@
+package v3
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiffManifests(t *testing.T) {
+	current := `---
+# Source: app/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: default
+data:
+  a: "1"
+  b: "2"
+  c: "3"
+  d: "4"
+  e: "5"
+  f: "6"
+  g: "7"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: default
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: unchanged
+  namespace: default
+`
+	proposed := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: default
+data:
+  a: "1"
+  b: "2"
+  c: "3"
+  d: "4"
+  e: "5"
+  f: "6"
+  g: "8"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: unchanged
+  namespace: default
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: app
+  namespace: default
+`
+	tests := []struct {
+		name      string
+		current   string
+		proposed  string
+		wantCount string
+		wantDiff  string
+	}{
+		{
+			name:      "changed added and removed",
+			current:   current,
+			proposed:  proposed,
+			wantCount: "1 to add, 1 to change, 1 to remove",
+			wantDiff: `--- ConfigMap default/app
++++ ConfigMap default/app
+   d: "4"
+   e: "5"
+   f: "6"
+-  g: "7"
++  g: "8"
+ kind: ConfigMap
+ metadata:
+   name: app
+--- Service default/app
++++ Service default/app
+-apiVersion: v1
+-kind: Service
+-metadata:
+-  name: app
+-  namespace: default
+-spec:
+-  ports:
+-  - port: 80
+--- ServiceAccount default/app
++++ ServiceAccount default/app
++apiVersion: v1
++kind: ServiceAccount
++metadata:
++  name: app
++  namespace: default
+`,
+		},
+		{
+			name:      "nothing changed",
+			current:   current,
+			proposed:  current,
+			wantCount: "0 to add, 0 to change, 0 to remove",
+		},
+		{
+			name:      "first install",
+			proposed:  "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: app\n",
+			wantCount: "1 to add, 0 to change, 0 to remove",
+			wantDiff:  "--- Namespace app\n+++ Namespace app\n+apiVersion: v1\n+kind: Namespace\n+metadata:\n+  name: app\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffManifests(tt.current, tt.proposed)
+			if err != nil {
+				t.Fatalf("DiffManifests() error = %v", err)
+			}
+			if got.String() != tt.wantCount {
+				t.Errorf("DiffManifests() counts = %v, want %v", got, tt.wantCount)
+			}
+			if got.Diff != tt.wantDiff {
+				t.Errorf("DiffManifests() diff =\n%v\nwant\n%v", got.Diff, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func TestDiffManifestsRedactsSecrets(t *testing.T) {
+	secret := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\ndata:\n  password: %s\n  user: YWRtaW4=\nstringData:\n  token: %s\n"
+	got, err := DiffManifests(fmt.Sprintf(secret, "c2VjcmV0MQ==", "first-token"), fmt.Sprintf(secret, "c2VjcmV0Mg==", "first-token"))
+	if err != nil {
+		t.Fatalf("DiffManifests() error = %v", err)
+	}
+	if got.Changed != 1 {
+		t.Errorf("DiffManifests() = %v, want the changed password to change the Secret", got)
+	}
+	for _, value := range []string{"c2VjcmV0MQ==", "c2VjcmV0Mg==", "YWRtaW4=", "first-token"} {
+		if strings.Contains(got.Diff, value) {
+			t.Errorf("DiffManifests() diff holds secret value %v:\n%v", value, got.Diff)
+		}
+	}
+	if !strings.Contains(got.Diff, "-  password: (redacted ") || !strings.Contains(got.Diff, "+  password: (redacted ") {
+		t.Errorf("DiffManifests() diff does not show the redacted password change:\n%v", got.Diff)
+	}
+	if strings.Contains(got.Diff, "-  token:") {
+		t.Errorf("DiffManifests() diff shows the unchanged token as changed:\n%v", got.Diff)
+	}
+}
+
+func TestDiffLinesLargeChange(t *testing.T) {
+	var from, to []string
+	for n := 0; n < 5000; n++ {
+		from = append(from, fmt.Sprintf("old %d", n))
+		to = append(to, fmt.Sprintf("new %d", n))
+	}
+	from = append([]string{"kind: ConfigMap"}, from...)
+	to = append([]string{"kind: ConfigMap"}, to...)
+
+	got := diffLines(from, to)
+	if lines := strings.Count(got, "\n"); lines != 10001 {
+		t.Errorf("diffLines() = %v lines, want the unchanged line and every line removed and added", lines)
+	}
+	if !strings.HasPrefix(got, " kind: ConfigMap\n-old 0\n") || !strings.HasSuffix(got, "+new 4999\n") {
+		t.Errorf("diffLines() does not start with the unchanged line and end with the last added line")
+	}
+}
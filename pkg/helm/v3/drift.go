@@ -0,0 +1,241 @@
+package v3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"helm.sh/helm/v3/pkg/release"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	cliResource "k8s.io/cli-runtime/pkg/resource"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DriftedObject is an object of a helm release whose live state no longer matches the release manifest
+type DriftedObject struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Field is the first field that differs, empty when the object is missing
+	Field string
+}
+
+func (d DriftedObject) String() string {
+	if d.Field == "" {
+		return fmt.Sprintf("%s %s/%s is missing", d.Kind, d.Namespace, d.Name)
+	}
+	return fmt.Sprintf("%s %s/%s changed %s", d.Kind, d.Namespace, d.Name, d.Field)
+}
+
+// DetectDrift compares the manifest stored in the helm release with the live objects in the cluster.
+// Only fields set in the manifest are compared, so defaults and fields managed by the api server are ignored.
+func (h *HelmV3) DetectDrift(releaseInfo *release.Release) ([]DriftedObject, error) {
+	resources, errBuilding := h.actionConfig.KubeClient.Build(bytes.NewBufferString(releaseInfo.Manifest), false)
+	if errBuilding != nil {
+		return nil, errBuilding
+	}
+
+	var drifted []DriftedObject
+	for _, info := range resources {
+		desired, errConverting := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if errConverting != nil {
+			return nil, errConverting
+		}
+		driftedObject := DriftedObject{Kind: info.Mapping.GroupVersionKind.Kind, Namespace: info.Namespace, Name: info.Name}
+
+		liveObject, errGettingLive := cliResource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name, false)
+		if errGettingLive != nil {
+			if apiErrors.IsNotFound(errGettingLive) {
+				drifted = append(drifted, driftedObject)
+				continue
+			}
+			return nil, errGettingLive
+		}
+		live, errConverting := runtime.DefaultUnstructuredConverter.ToUnstructured(liveObject)
+		if errConverting != nil {
+			return nil, errConverting
+		}
+
+		if field := driftedField(desired, live); field != "" {
+			driftedObject.Field = field
+			drifted = append(drifted, driftedObject)
+		}
+	}
+	return drifted, nil
+}
+
+// driftedField returns the path of the first field set in desired that differs in live, or "" when none do.
+// Status and server populated metadata are skipped, labels and annotations are compared. Fields are compared in
+// sorted order so the same drift is always reported as the same field.
+func driftedField(desired, live map[string]interface{}) string {
+	desired = foldStringData(desired)
+	for _, key := range sortedKeys(desired) {
+		desiredValue := desired[key]
+		switch key {
+		case "status":
+			continue
+		case "metadata":
+			desiredMeta, _ := desiredValue.(map[string]interface{})
+			liveMeta, _ := live[key].(map[string]interface{})
+			for _, metaKey := range []string{"labels", "annotations"} {
+				if _, ok := desiredMeta[metaKey]; !ok {
+					continue
+				}
+				if field := diffValue(desiredMeta[metaKey], liveMeta[metaKey], "metadata."+metaKey); field != "" {
+					return field
+				}
+			}
+			continue
+		}
+		if field := diffValue(desiredValue, live[key], key); field != "" {
+			return field
+		}
+	}
+	return ""
+}
+
+// foldStringData moves the stringData of a Secret into its data, the api server does the same and never returns stringData
+func foldStringData(desired map[string]interface{}) map[string]interface{} {
+	stringData, ok := desired["stringData"].(map[string]interface{})
+	if desired["kind"] != "Secret" || !ok {
+		return desired
+	}
+	folded := make(map[string]interface{}, len(desired))
+	for key, value := range desired {
+		folded[key] = value
+	}
+	data := map[string]interface{}{}
+	if desiredData, ok := desired["data"].(map[string]interface{}); ok {
+		for key, value := range desiredData {
+			data[key] = value
+		}
+	}
+	for key, value := range stringData {
+		// stringData wins over data, as it does on the api server
+		data[key] = base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(value)))
+	}
+	folded["data"] = data
+	delete(folded, "stringData")
+	return folded
+}
+
+func diffValue(desired, live interface{}, path string) string {
+	if isUnset(desired) {
+		// e.g. clusterIP: "" is filled in by the api server
+		return ""
+	}
+	switch desiredValue := desired.(type) {
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			return path
+		}
+		for _, key := range sortedKeys(desiredValue) {
+			if field := diffValue(desiredValue[key], liveValue[key], joinFieldPath(path, key)); field != "" {
+				return field
+			}
+		}
+		return ""
+	case []interface{}:
+		liveValue, ok := live.([]interface{})
+		if !ok || len(liveValue) != len(desiredValue) {
+			return path
+		}
+		for i := range desiredValue {
+			if field := diffValue(desiredValue[i], liveValue[i], fmt.Sprintf("%s[%d]", path, i)); field != "" {
+				return field
+			}
+		}
+		return ""
+	}
+
+	if reflect.DeepEqual(desired, live) {
+		return ""
+	}
+	// manifests and live objects do not agree on int64 vs float64
+	if desiredNumber, ok := toFloat(desired); ok {
+		if liveNumber, ok := toFloat(live); ok && desiredNumber == liveNumber {
+			return ""
+		}
+	}
+	// the api server normalises resource quantities, e.g. memory 1024 comes back as "1Ki" and cpu "0.5" as "500m"
+	if quantityField.MatchString(path) {
+		if desiredQuantity, ok := toQuantity(desired); ok {
+			if liveQuantity, ok := toQuantity(live); ok && desiredQuantity.Cmp(liveQuantity) == 0 {
+				return ""
+			}
+		}
+	}
+	return path
+}
+
+// quantityField matches the paths of resource quantities, e.g. resources.limits.memory or a ResourceQuota spec.hard entry
+var quantityField = regexp.MustCompile(`(^|\.)(requests|limits|hard|capacity|allocatable|overhead)(\.[^.\[]+|\[[^\]]+\])$`)
+
+// isUnset reports whether a manifest value leaves the field to the api server: nil, empty strings, zero numbers and
+// empty maps and lists. Booleans are always compared.
+func isUnset(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	number, ok := toFloat(value)
+	return ok && number == 0
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// toQuantity reads numbers and quantity strings as a resource quantity
+func toQuantity(value interface{}) (resource.Quantity, bool) {
+	var text string
+	switch v := value.(type) {
+	case int64:
+		text = fmt.Sprint(v)
+	case int:
+		text = fmt.Sprint(v)
+	case float64:
+		text = fmt.Sprint(v)
+	case string:
+		text = v
+	default:
+		return resource.Quantity{}, false
+	}
+	quantity, errParsing := resource.ParseQuantity(text)
+	return quantity, errParsing == nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinFieldPath(path, key string) string {
+	if strings.ContainsAny(key, "./") {
+		return fmt.Sprintf("%s[%s]", path, key)
+	}
+	return path + "." + key
+}
@@ -0,0 +1,195 @@
+package v3
+
+import "testing"
+
+func Test_driftedField(t *testing.T) {
+	desiredDeployment := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":   "jenkins",
+			"labels": map[string]interface{}{"app.kubernetes.io/name": "jenkins"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "jenkins", "image": "jenkins:2.235"},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		live map[string]interface{}
+		want string
+	}{
+		{
+			name: "server managed fields and defaults are ignored",
+			live: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":            "jenkins",
+					"uid":             "1234",
+					"resourceVersion": "42",
+					"labels":          map[string]interface{}{"app.kubernetes.io/name": "jenkins"},
+				},
+				"spec": map[string]interface{}{
+					"replicas":             float64(1),
+					"revisionHistoryLimit": int64(10),
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{"name": "jenkins", "image": "jenkins:2.235", "imagePullPolicy": "IfNotPresent"},
+							},
+						},
+					},
+				},
+				"status": map[string]interface{}{"replicas": int64(1)},
+			},
+			want: "",
+		},
+		{
+			name: "scaled by hand",
+			live: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":   "jenkins",
+					"labels": map[string]interface{}{"app.kubernetes.io/name": "jenkins"},
+				},
+				"spec": map[string]interface{}{
+					"replicas": int64(3),
+					"template": desiredDeployment["spec"].(map[string]interface{})["template"],
+				},
+			},
+			want: "spec.replicas",
+		},
+		{
+			name: "image edited",
+			live: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":   "jenkins",
+					"labels": map[string]interface{}{"app.kubernetes.io/name": "jenkins"},
+				},
+				"spec": map[string]interface{}{
+					"replicas": int64(1),
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{"name": "jenkins", "image": "jenkins:latest"},
+							},
+						},
+					},
+				},
+			},
+			want: "spec.template.spec.containers[0].image",
+		},
+		{
+			name: "label removed",
+			live: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata":   map[string]interface{}{"name": "jenkins"},
+				"spec":       desiredDeployment["spec"],
+			},
+			want: "metadata.labels",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := driftedField(desiredDeployment, tt.live); got != tt.want {
+				t.Errorf("driftedField() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_diffValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired interface{}
+		live    interface{}
+		path    string
+		want    string
+	}{
+		{name: "int and float", desired: int64(3), live: float64(3), path: "replicas", want: ""},
+		{name: "memory normalised", desired: int64(1024), live: "1Ki", path: "resources.limits.memory", want: ""},
+		{name: "cpu normalised", desired: "0.5", live: "500m", path: "resources.requests.cpu", want: ""},
+		{name: "quantity with a dotted name", desired: "1", live: "1000m", path: "spec.hard[requests.nvidia.com/gpu]", want: ""},
+		{name: "memory changed", desired: "512Mi", live: "1Gi", path: "resources.limits.memory", want: "resources.limits.memory"},
+		{name: "text changed", desired: "jenkins:2.235", live: "jenkins:latest", path: "image", want: "image"},
+		{name: "tags are not quantities", desired: "1", live: "1.0", path: "tag", want: "tag"},
+		{name: "empty string filled by the server", desired: "", live: "10.96.0.1", path: "spec.clusterIP", want: ""},
+		{name: "zero filled by the server", desired: int64(0), live: int64(30080), path: "nodePort", want: ""},
+		{name: "false is compared", desired: false, live: true, path: "automountServiceAccountToken", want: "automountServiceAccountToken"},
+		{
+			name:    "first drifted field in sorted order",
+			desired: map[string]interface{}{"c": "1", "b": "1", "a": "1", "d": "1"},
+			live:    map[string]interface{}{"c": "2", "b": "2", "a": "1", "d": "2"},
+			path:    "memory",
+			want:    "memory.b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// map order is random, the same drift has to be reported the same way every time
+			for i := 0; i < 20; i++ {
+				if got := diffValue(tt.desired, tt.live, tt.path); got != tt.want {
+					t.Fatalf("diffValue() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_driftedFieldSecret(t *testing.T) {
+	desiredSecret := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "jenkins"},
+		"data":       map[string]interface{}{"jenkins-admin-user": "YWRtaW4="},
+		"stringData": map[string]interface{}{"jenkins-admin-password": "secret"},
+	}
+
+	tests := []struct {
+		name string
+		live map[string]interface{}
+		want string
+	}{
+		{
+			name: "stringData comes back as data",
+			live: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata":   map[string]interface{}{"name": "jenkins"},
+				"data":       map[string]interface{}{"jenkins-admin-user": "YWRtaW4=", "jenkins-admin-password": "c2VjcmV0"},
+				"type":       "Opaque",
+			},
+			want: "",
+		},
+		{
+			name: "stringData value changed",
+			live: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata":   map[string]interface{}{"name": "jenkins"},
+				"data":       map[string]interface{}{"jenkins-admin-user": "YWRtaW4=", "jenkins-admin-password": "Y2hhbmdlZA=="},
+			},
+			want: "data.jenkins-admin-password",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := driftedField(desiredSecret, tt.live); got != tt.want {
+				t.Errorf("driftedField() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
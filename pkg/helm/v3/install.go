@@ -3,6 +3,7 @@ package v3
 import (
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/release"
 	"os"
 	"time"
@@ -18,6 +19,7 @@ type InstallOptions struct {
 	Atomic                   bool
 	DisableOpenAPIValidation bool
 	IncludeCRDs              bool
+	PostRenderer             postrender.PostRenderer
 }
 
 //InstallRelease installs helm charts, assuming a chart path locally exists
@@ -50,5 +52,6 @@ func (i InstallOptions) setInstallOptions(installAction *action.Install) *action
 	installAction.Atomic = i.Atomic
 	installAction.DisableOpenAPIValidation = i.DisableOpenAPIValidation
 	installAction.IncludeCRDs = i.IncludeCRDs
+	installAction.PostRenderer = i.PostRenderer
 	return installAction
 }
@@ -0,0 +1,156 @@
+package v3
+
+import (
+	"fmt"
+	"github.com/coveros/genoa/pkg"
+	"github.com/coveros/genoa/pkg/utils"
+	"helm.sh/helm/v3/pkg/repo"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChartMirror serves charts from an offline bundle on local disk instead of the remote repos.
+// A bundle has one directory per repo alias, each holding an index.yaml and the chart tarballs it refers to.
+type ChartMirror struct {
+	Dir string
+}
+
+// BundleChart is a single chart version to ship in an offline bundle
+type BundleChart struct {
+	Repo    string
+	Chart   string
+	Version string
+}
+
+// PullChart copies the chart from the mirror into the chart cache, verifying its digest and,
+// when keyrings are given, the .prov file shipped next to it.
+// The returned func must be called once the chart is no longer needed so the cache is free to evict it.
+func (m ChartMirror) PullChart(cache *ChartCache, repoAlias, chart, version string, keyrings [][]byte) (string, func(), error) {
+	repoDir := filepath.Join(m.Dir, strings.ToLower(repoAlias))
+	repoIndexFile, errLoadingIndex := repo.LoadIndexFile(filepath.Join(repoDir, "index.yaml"))
+	if errLoadingIndex != nil {
+		if os.IsNotExist(errLoadingIndex) {
+			return "", nil, pkg.ErrorChartEntryNotFoundInRepoIndex{Message: fmt.Sprintf("%v repo is not in the chart mirror", repoAlias)}
+		}
+		return "", nil, errLoadingIndex
+	}
+
+	chartVersion, errFindingChart := findChartVersionFromCacheFile(repoIndexFile, chart, version)
+	if errFindingChart != nil || len(chartVersion.URLs) == 0 {
+		// there is nothing to refresh in an air gapped cluster, the chart has to be added to the bundle
+		return "", nil, pkg.ErrorChartEntryNotFoundInRepoIndex{Message: fmt.Sprintf("%v/%v-%v is not in the chart mirror", repoAlias, chart, version)}
+	}
+	mirroredChartPath := filepath.Join(repoDir, filepath.Base(chartVersion.URLs[0]))
+
+	cacheKey := ChartCacheKey{Repo: repoAlias, Chart: chart, Version: version, Digest: chartVersion.Digest}
+	chartPath, releaseChart, errGettingChart := cache.Get(cacheKey, func(dest string) error {
+		return copyFile(mirroredChartPath, dest)
+	})
+	if errGettingChart != nil {
+		return "", nil, errGettingChart
+	}
+
+	if len(keyrings) > 0 {
+		if errVerifying := verifyChartWithProvFile(chartPath, mirroredChartPath+".prov", keyrings); errVerifying != nil {
+			releaseChart()
+			return "", nil, errVerifying
+		}
+	}
+	return chartPath, releaseChart, nil
+}
+
+// BuildBundle downloads every chart into dir, laid out the way ChartMirror expects, so it can be carried across an air gap.
+// Provenance files are bundled when the repo has them.
+func BuildBundle(charts []BundleChart, repoOptions map[string]RepoOptions, dir string) error {
+	h := &HelmV3{settings: DefaultEnvSettings()}
+	indexes := map[string]*repo.IndexFile{}
+
+	for _, bundleChart := range charts {
+		repoAlias := strings.ToLower(bundleChart.Repo)
+		repoEntry, errLookingUpRepo := h.GetRepoEntryFromRepoConfig(repoAlias)
+		if errLookingUpRepo != nil {
+			return errLookingUpRepo
+		}
+		repoOpts := repoOptions[repoAlias]
+
+		// always bundle from a fresh index
+		if _, ok := indexes[repoAlias]; !ok {
+			if errRefreshing := h.RefreshRepoIndex(repoEntry.Name, repoOpts); errRefreshing != nil {
+				return errRefreshing
+			}
+			indexes[repoAlias] = repo.NewIndexFile()
+		}
+
+		chartVersion, errFindingChart := h.getChartVersionFromRepoIndex(repoEntry.Name, bundleChart.Chart, bundleChart.Version)
+		if errFindingChart != nil {
+			return errFindingChart
+		}
+
+		client, errCreatingClient := newRepoHTTPClient(repoEntry, repoOpts)
+		if errCreatingClient != nil {
+			return errCreatingClient
+		}
+
+		downloadUrl := chartVersion.URLs[0]
+		if !strings.HasPrefix(downloadUrl, "https://") && !strings.HasPrefix(downloadUrl, "http://") {
+			downloadUrl = fmt.Sprintf("%s/%s", utils.TrimSuffix(repoEntry.URL, "/"), downloadUrl)
+		}
+
+		repoDir := filepath.Join(dir, repoAlias)
+		if errMakingDir := os.MkdirAll(repoDir, 0755); errMakingDir != nil {
+			return errMakingDir
+		}
+		chartTarballName := fmt.Sprintf("%s-%s.tgz", chartVersion.Name, chartVersion.Version)
+		chartPath := filepath.Join(repoDir, chartTarballName)
+		helmInfoLogF("bundling %v/%v-%v", repoAlias, bundleChart.Chart, bundleChart.Version)
+		if errDownloading := utils.DownloadFile(client, chartPath, downloadUrl, repoEntry.Username, repoEntry.Password); errDownloading != nil {
+			return errDownloading
+		}
+
+		digest, _, errHashing := sha256File(chartPath)
+		if errHashing != nil {
+			return errHashing
+		}
+		if chartVersion.Digest != "" && !strings.EqualFold(chartVersion.Digest, digest) {
+			return pkg.ErrorChartDigestMismatch{Message: fmt.Sprintf("%v digest mismatch, repo index has %v but downloaded chart has %v", downloadUrl, chartVersion.Digest, digest)}
+		}
+
+		errDownloadingProv := utils.DownloadFile(client, chartPath+".prov", downloadUrl+".prov", repoEntry.Username, repoEntry.Password)
+		if _, notFound := errDownloadingProv.(pkg.ErrorRepoNotFound); notFound {
+			os.Remove(chartPath + ".prov")
+		} else if errDownloadingProv != nil {
+			return errDownloadingProv
+		}
+
+		if !indexes[repoAlias].Has(chartVersion.Name, chartVersion.Version) {
+			indexes[repoAlias].Add(chartVersion.Metadata, chartTarballName, "", digest)
+		}
+	}
+
+	for repoAlias, index := range indexes {
+		index.SortEntries()
+		if errWritingIndex := index.WriteFile(filepath.Join(dir, repoAlias, "index.yaml"), 0644); errWritingIndex != nil {
+			return errWritingIndex
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,198 @@
+package v3
+
+import (
+	"github.com/coveros/genoa/pkg"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/repo"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTestIndex writes the index.yaml of a repo dir, charts are listed with the digest they are given
+func writeTestIndex(t *testing.T, dir, baseUrl string, digests map[string]string) {
+	index := repo.NewIndexFile()
+	for chartPath, digest := range digests {
+		loadedChart, errLoading := loader.Load(chartPath)
+		if errLoading != nil {
+			t.Fatal(errLoading)
+		}
+		index.Add(loadedChart.Metadata, filepath.Base(chartPath), baseUrl, digest)
+	}
+	if errWriting := index.WriteFile(filepath.Join(dir, "index.yaml"), 0644); errWriting != nil {
+		t.Fatal(errWriting)
+	}
+}
+
+func testDigest(t *testing.T, chartPath string) string {
+	digest, _, errHashing := sha256File(chartPath)
+	if errHashing != nil {
+		t.Fatal(errHashing)
+	}
+	return digest
+}
+
+// setTestHelmHome points the helm repo config and cache at dir for the duration of the test
+func setTestHelmHome(t *testing.T, dir string) func() {
+	restore := map[string]string{}
+	for key, value := range map[string]string{
+		"HELM_REPOSITORY_CONFIG": filepath.Join(dir, "repositories.yaml"),
+		"HELM_REPOSITORY_CACHE":  filepath.Join(dir, "helm", "repository"),
+		// helm refreshes repo indexes into the xdg cache, whatever the repository cache is
+		"XDG_CACHE_HOME": dir,
+	} {
+		restore[key] = os.Getenv(key)
+		os.Setenv(key, value)
+	}
+	return func() {
+		for key, value := range restore {
+			os.Setenv(key, value)
+		}
+	}
+}
+
+func TestChartMirror_PullChart(t *testing.T) {
+	dir, errCreatingDir := ioutil.TempDir("", "genoa-mirror")
+	if errCreatingDir != nil {
+		t.Fatal(errCreatingDir)
+	}
+	defer os.RemoveAll(dir)
+
+	signingKey, signingKeyring := newTestKey(t, "chart signer")
+	repoDir := filepath.Join(dir, "bundle", "stable")
+	jenkins := newTestChart(t, repoDir, "jenkins", "1.0.0")
+	signTestChart(t, jenkins, signingKey)
+	nginx := newTestChart(t, repoDir, "nginx", "1.0.0")
+	broken := newTestChart(t, repoDir, "broken", "1.0.0")
+	writeTestIndex(t, repoDir, "", map[string]string{
+		jenkins: testDigest(t, jenkins),
+		nginx:   testDigest(t, nginx),
+		broken:  testDigest(t, jenkins),
+	})
+
+	cache, errCreatingCache := NewChartCache(filepath.Join(dir, "cache"), 1<<20)
+	if errCreatingCache != nil {
+		t.Fatal(errCreatingCache)
+	}
+	mirror := ChartMirror{Dir: filepath.Join(dir, "bundle")}
+
+	tests := []struct {
+		name     string
+		repo     string
+		chart    string
+		version  string
+		keyrings [][]byte
+		want     string
+		wantErr  error
+	}{
+		{name: "chart in the bundle", repo: "stable", chart: "jenkins", version: "1.0.0", want: jenkins},
+		{name: "repo alias is not case sensitive", repo: "Stable", chart: "nginx", version: "1.0.0", want: nginx},
+		{name: "signed chart", repo: "stable", chart: "jenkins", version: "1.0.0", keyrings: [][]byte{signingKeyring}, want: jenkins},
+		{name: "chart without prov file", repo: "stable", chart: "nginx", version: "1.0.0", keyrings: [][]byte{signingKeyring}, wantErr: pkg.ErrorChartVerificationFailed{}},
+		{name: "version not in the bundle", repo: "stable", chart: "jenkins", version: "2.0.0", wantErr: pkg.ErrorChartEntryNotFoundInRepoIndex{}},
+		{name: "chart not in the bundle", repo: "stable", chart: "mongodb", version: "1.0.0", wantErr: pkg.ErrorChartEntryNotFoundInRepoIndex{}},
+		{name: "repo not in the bundle", repo: "incubator", chart: "jenkins", version: "1.0.0", wantErr: pkg.ErrorChartEntryNotFoundInRepoIndex{}},
+		{name: "digest mismatch", repo: "stable", chart: "broken", version: "1.0.0", wantErr: pkg.ErrorChartDigestMismatch{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartPath, releaseChart, err := mirror.PullChart(cache, tt.repo, tt.chart, tt.version, tt.keyrings)
+			if tt.wantErr != nil {
+				if reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr) {
+					t.Fatalf("PullChart() error = %T %v, want %T", err, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PullChart() error = %v", err)
+			}
+			defer releaseChart()
+			if got, want := testDigest(t, chartPath), testDigest(t, tt.want); got != want {
+				t.Errorf("PullChart() returned a chart with digest %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBuildBundle(t *testing.T) {
+	dir, errCreatingDir := ioutil.TempDir("", "genoa-bundle")
+	if errCreatingDir != nil {
+		t.Fatal(errCreatingDir)
+	}
+	defer os.RemoveAll(dir)
+	defer setTestHelmHome(t, filepath.Join(dir, "helm"))()
+
+	signingKey, signingKeyring := newTestKey(t, "chart signer")
+	repoDir := filepath.Join(dir, "repo")
+	jenkins := newTestChart(t, repoDir, "jenkins", "1.0.0")
+	signTestChart(t, jenkins, signingKey)
+	nginx := newTestChart(t, repoDir, "nginx", "1.0.0")
+	broken := newTestChart(t, repoDir, "broken", "1.0.0")
+
+	server := httptest.NewServer(http.FileServer(http.Dir(repoDir)))
+	defer server.Close()
+	writeTestIndex(t, repoDir, server.URL, map[string]string{
+		jenkins: testDigest(t, jenkins),
+		nginx:   testDigest(t, nginx),
+		broken:  testDigest(t, jenkins),
+	})
+	repoFile := repo.NewFile()
+	repoFile.Add(&repo.Entry{Name: "stable", URL: server.URL})
+	if errMakingDir := os.MkdirAll(filepath.Join(dir, "helm"), 0755); errMakingDir != nil {
+		t.Fatal(errMakingDir)
+	}
+	if errWriting := repoFile.WriteFile(filepath.Join(dir, "helm", "repositories.yaml"), 0644); errWriting != nil {
+		t.Fatal(errWriting)
+	}
+
+	tests := []struct {
+		name    string
+		charts  []BundleChart
+		wantErr error
+	}{
+		{name: "charts with and without prov files", charts: []BundleChart{{Repo: "stable", Chart: "jenkins", Version: "1.0.0"}, {Repo: "stable", Chart: "nginx", Version: "1.0.0"}}},
+		{name: "chart not in the repo index", charts: []BundleChart{{Repo: "stable", Chart: "jenkins", Version: "2.0.0"}}, wantErr: pkg.ErrorHelmRepoNeedsRefresh{}},
+		{name: "repo not configured", charts: []BundleChart{{Repo: "incubator", Chart: "jenkins", Version: "1.0.0"}}, wantErr: pkg.ErrorHelmRepoNotFoundInRepoConfig{}},
+		{name: "digest mismatch", charts: []BundleChart{{Repo: "stable", Chart: "broken", Version: "1.0.0"}}, wantErr: pkg.ErrorChartDigestMismatch{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundleDir, _ := ioutil.TempDir(dir, "bundle")
+			err := BuildBundle(tt.charts, nil, bundleDir)
+			if tt.wantErr != nil {
+				if reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr) {
+					t.Fatalf("BuildBundle() error = %T %v, want %T", err, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildBundle() error = %v", err)
+			}
+
+			// the bundle serves the charts it was built with
+			cache, errCreatingCache := NewChartCache(filepath.Join(bundleDir, "cache"), 1<<20)
+			if errCreatingCache != nil {
+				t.Fatal(errCreatingCache)
+			}
+			mirror := ChartMirror{Dir: bundleDir}
+			for _, bundled := range tt.charts {
+				var keyrings [][]byte
+				if bundled.Chart == "jenkins" {
+					keyrings = [][]byte{signingKeyring}
+				}
+				_, releaseChart, errPulling := mirror.PullChart(cache, bundled.Repo, bundled.Chart, bundled.Version, keyrings)
+				if errPulling != nil {
+					t.Fatalf("PullChart() from the bundle error = %v", errPulling)
+				}
+				releaseChart()
+			}
+			if _, errStating := os.Stat(filepath.Join(bundleDir, "stable", "nginx-1.0.0.tgz.prov")); !os.IsNotExist(errStating) {
+				t.Errorf("BuildBundle() bundled a prov file the repo does not have")
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package v3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"strings"
+)
+
+// PostRenderer patches the manifests rendered from a chart before helm applies them.
+// Patches are applied first, then the labels and annotations are added.
+type PostRenderer struct {
+	Patches []Patch
+	// Labels are added to every object and to the pod templates of workloads
+	Labels map[string]string
+	// Annotations are added to every object
+	Annotations map[string]string
+}
+
+// Patch is an inline kustomize patch, either a strategic merge patch or a JSON 6902 patch
+type Patch struct {
+	// Target selects the objects to patch, a strategic merge patch without a target patches the object it names
+	Target *PatchTarget
+	Patch  string
+}
+
+// PatchTarget selects objects like a kustomize patch target, empty fields match anything
+type PatchTarget struct {
+	Group         string
+	Version       string
+	Kind          string
+	Name          string
+	Namespace     string
+	LabelSelector string
+}
+
+// PostRenderers runs post renderers one after the other, it implements the helm postrender.PostRenderer interface
+type PostRenderers []PostRenderer
+
+func (p PostRenderers) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	objects, errParsing := parseManifests(renderedManifests.String())
+	if errParsing != nil {
+		return nil, errParsing
+	}
+	for _, postRenderer := range p {
+		for i, patch := range postRenderer.Patches {
+			if errPatching := patch.apply(objects); errPatching != nil {
+				return nil, fmt.Errorf("post renderer patch %d: %v", i, errPatching)
+			}
+		}
+		for _, obj := range objects {
+			addLabels(obj, postRenderer.Labels)
+			addAnnotations(obj, postRenderer.Annotations)
+		}
+	}
+
+	modifiedManifests := &bytes.Buffer{}
+	for _, obj := range objects {
+		manifest, errMarshaling := yaml.Marshal(obj.Object)
+		if errMarshaling != nil {
+			return nil, errMarshaling
+		}
+		modifiedManifests.WriteString("---\n")
+		modifiedManifests.Write(manifest)
+	}
+	return modifiedManifests, nil
+}
+
+func parseManifests(manifests string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	for _, manifest := range strings.Split("\n"+manifests, "\n---") {
+		obj := map[string]interface{}{}
+		if errParsing := yaml.Unmarshal([]byte(manifest), &obj); errParsing != nil {
+			return nil, errParsing
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		objects = append(objects, &unstructured.Unstructured{Object: obj})
+	}
+	return objects, nil
+}
+
+// apply patches every matching object, a YAML list is a JSON 6902 patch and anything else a strategic merge patch
+func (p Patch) apply(objects []*unstructured.Unstructured) error {
+	patchJSON, errConverting := yaml.YAMLToJSON([]byte(p.Patch))
+	if errConverting != nil {
+		return errConverting
+	}
+	isJSON6902 := bytes.HasPrefix(bytes.TrimSpace(patchJSON), []byte("["))
+
+	target := p.Target
+	if target == nil {
+		if isJSON6902 {
+			return fmt.Errorf("a JSON 6902 patch needs a target")
+		}
+		patchObj := &unstructured.Unstructured{}
+		if errParsing := json.Unmarshal(patchJSON, &patchObj.Object); errParsing != nil {
+			return errParsing
+		}
+		gvk := patchObj.GroupVersionKind()
+		target = &PatchTarget{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind, Name: patchObj.GetName(), Namespace: patchObj.GetNamespace()}
+	}
+
+	for _, obj := range objects {
+		matches, errMatching := target.matches(obj)
+		if errMatching != nil {
+			return errMatching
+		}
+		if !matches {
+			continue
+		}
+		original, errMarshaling := json.Marshal(obj.Object)
+		if errMarshaling != nil {
+			return errMarshaling
+		}
+		var patched []byte
+		var errPatching error
+		if isJSON6902 {
+			patched, errPatching = applyJSON6902Patch(original, patchJSON)
+		} else {
+			patched, errPatching = applyStrategicMergePatch(obj.GroupVersionKind(), original, patchJSON)
+		}
+		if errPatching != nil {
+			return fmt.Errorf("%v %v: %v", obj.GetKind(), obj.GetName(), errPatching)
+		}
+		if errParsing := json.Unmarshal(patched, &obj.Object); errParsing != nil {
+			return errParsing
+		}
+	}
+	return nil
+}
+
+func applyJSON6902Patch(original, patchJSON []byte) ([]byte, error) {
+	patch, errDecoding := jsonpatch.DecodePatch(patchJSON)
+	if errDecoding != nil {
+		return nil, errDecoding
+	}
+	return patch.Apply(original)
+}
+
+// applyStrategicMergePatch merges with the patch strategies of built in kinds, other kinds get a JSON merge patch like kustomize does
+func applyStrategicMergePatch(gvk schema.GroupVersionKind, original, patchJSON []byte) ([]byte, error) {
+	dataStruct, errCreating := scheme.Scheme.New(gvk)
+	if errCreating != nil {
+		return jsonpatch.MergePatch(original, patchJSON)
+	}
+	return strategicpatch.StrategicMergePatch(original, patchJSON, dataStruct)
+}
+
+func (t *PatchTarget) matches(obj *unstructured.Unstructured) (bool, error) {
+	gvk := obj.GroupVersionKind()
+	if (t.Group != "" && t.Group != gvk.Group) ||
+		(t.Version != "" && t.Version != gvk.Version) ||
+		(t.Kind != "" && t.Kind != gvk.Kind) ||
+		(t.Name != "" && t.Name != obj.GetName()) ||
+		(t.Namespace != "" && t.Namespace != obj.GetNamespace()) {
+		return false, nil
+	}
+	if t.LabelSelector == "" {
+		return true, nil
+	}
+	selector, errParsing := labels.Parse(t.LabelSelector)
+	if errParsing != nil {
+		return false, errParsing
+	}
+	return selector.Matches(labels.Set(obj.GetLabels())), nil
+}
+
+func addLabels(obj *unstructured.Unstructured, extraLabels map[string]string) {
+	if len(extraLabels) == 0 {
+		return
+	}
+	obj.SetLabels(mergeStringMaps(obj.GetLabels(), extraLabels))
+	// pods of workloads get them too, selectors are left alone since they are immutable
+	if template, found, _ := unstructured.NestedMap(obj.Object, "spec", "template"); found {
+		templateLabels, _, _ := unstructured.NestedStringMap(template, "metadata", "labels")
+		_ = unstructured.SetNestedStringMap(obj.Object, mergeStringMaps(templateLabels, extraLabels), "spec", "template", "metadata", "labels")
+	}
+}
+
+func addAnnotations(obj *unstructured.Unstructured, extraAnnotations map[string]string) {
+	if len(extraAnnotations) == 0 {
+		return
+	}
+	obj.SetAnnotations(mergeStringMaps(obj.GetAnnotations(), extraAnnotations))
+}
+
+func mergeStringMaps(base, extra map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(extra))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range extra {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,203 @@
+package v3
+
+import (
+	"bytes"
+	"github.com/ghodss/yaml"
+	"reflect"
+	"testing"
+)
+
+const renderedManifests = `---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  labels:
+    app: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      containers:
+      - name: app
+        image: app:1.0
+---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  ports:
+  - port: 80
+`
+
+func TestPostRenderers_Run(t *testing.T) {
+	tests := []struct {
+		name          string
+		postRenderers PostRenderers
+		want          []string
+		wantErr       bool
+	}{
+		{
+			name: "strategic merge patch naming its object",
+			postRenderers: PostRenderers{{Patches: []Patch{{Patch: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      tolerations:
+      - key: dedicated
+        operator: Exists
+      containers:
+      - name: sidecar
+        image: proxy:2.0
+`}}}},
+			want: []string{`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: app
+  name: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      containers:
+      - image: proxy:2.0
+        name: sidecar
+      - image: app:1.0
+        name: app
+      tolerations:
+      - key: dedicated
+        operator: Exists
+`, `apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  ports:
+  - port: 80
+`},
+		},
+		{
+			name: "json 6902 patch with a target, labels and annotations",
+			postRenderers: PostRenderers{{
+				Patches:     []Patch{{Target: &PatchTarget{Kind: "Service"}, Patch: `[{"op": "replace", "path": "/spec/ports/0/port", "value": 8080}]`}},
+				Labels:      map[string]string{"team": "ci"},
+				Annotations: map[string]string{"owner": "genoa"},
+			}},
+			want: []string{`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    owner: genoa
+  labels:
+    app: app
+    team: ci
+  name: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+        team: ci
+    spec:
+      containers:
+      - image: app:1.0
+        name: app
+`, `apiVersion: v1
+kind: Service
+metadata:
+  annotations:
+    owner: genoa
+  labels:
+    team: ci
+  name: app
+spec:
+  ports:
+  - port: 8080
+`},
+		},
+		{
+			name:          "label selector matching nothing",
+			postRenderers: PostRenderers{{Patches: []Patch{{Target: &PatchTarget{LabelSelector: "app=other"}, Patch: `[{"op": "remove", "path": "/spec"}]`}}}},
+			want: []string{`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: app
+  name: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      containers:
+      - image: app:1.0
+        name: app
+`, `apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  ports:
+  - port: 80
+`},
+		},
+		{
+			name:          "json 6902 patch without a target",
+			postRenderers: PostRenderers{{Patches: []Patch{{Patch: `[{"op": "remove", "path": "/spec"}]`}}}},
+			wantErr:       true,
+		},
+		{
+			name:          "json 6902 patch that does not apply",
+			postRenderers: PostRenderers{{Patches: []Patch{{Target: &PatchTarget{Kind: "Service"}, Patch: `[{"op": "replace", "path": "/spec/missing/0", "value": 1}]`}}}},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.postRenderers.Run(bytes.NewBufferString(renderedManifests))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			objects, err := parseManifests(got.String())
+			if err != nil {
+				t.Fatalf("Run() returned manifests that do not parse: %v", err)
+			}
+			var gotManifests []string
+			for _, obj := range objects {
+				manifest, _ := yaml.Marshal(obj.Object)
+				gotManifests = append(gotManifests, string(manifest))
+			}
+			if !reflect.DeepEqual(gotManifests, tt.want) {
+				t.Errorf("Run() = %v, want %v", gotManifests, tt.want)
+			}
+		})
+	}
+}
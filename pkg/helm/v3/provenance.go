@@ -0,0 +1,72 @@
+package v3
+
+import (
+	"fmt"
+	"github.com/coveros/genoa/pkg"
+	"github.com/coveros/genoa/pkg/utils"
+	"helm.sh/helm/v3/pkg/provenance"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// verifyChart downloads the .prov file that sits next to the chart in the repo and checks it against the keyrings
+func verifyChart(client *http.Client, chartPath, chartUrl, username, password string, keyrings [][]byte) error {
+	provFile, errCreatingProvFile := writeTempFile("genoa-*.prov", nil)
+	if errCreatingProvFile != nil {
+		return errCreatingProvFile
+	}
+	defer os.Remove(provFile)
+
+	provUrl := chartUrl + ".prov"
+	if errDownloadingProv := utils.DownloadFile(client, provFile, provUrl, username, password); errDownloadingProv != nil {
+		return pkg.ErrorChartVerificationFailed{Message: fmt.Sprintf("failed to download provenance file %v: %v", provUrl, errDownloadingProv)}
+	}
+	return verifyChartWithProvFile(chartPath, provFile, keyrings)
+}
+
+// verifyChartWithProvFile checks a chart against a local .prov file, it has to be signed by a key in every keyring
+func verifyChartWithProvFile(chartPath, provFile string, keyrings [][]byte) error {
+	for _, keyring := range keyrings {
+		if errVerifying := verifyChartWithKeyring(chartPath, provFile, keyring); errVerifying != nil {
+			return errVerifying
+		}
+	}
+	return nil
+}
+
+func verifyChartWithKeyring(chartPath, provFile string, keyring []byte) error {
+	keyringFile, errWritingKeyring := writeTempFile("genoa-keyring-*.gpg", keyring)
+	if errWritingKeyring != nil {
+		return errWritingKeyring
+	}
+	defer os.Remove(keyringFile)
+
+	signatory, errLoadingKeyring := provenance.NewFromKeyring(keyringFile, "")
+	if errLoadingKeyring != nil {
+		return pkg.ErrorChartVerificationFailed{Message: fmt.Sprintf("failed to load keyring: %v", errLoadingKeyring)}
+	}
+
+	verification, errVerifying := signatory.Verify(chartPath, provFile)
+	if errVerifying != nil {
+		return pkg.ErrorChartVerificationFailed{Message: fmt.Sprintf("%v failed provenance verification: %v", filepath.Base(chartPath), errVerifying)}
+	}
+	for name := range verification.SignedBy.Identities {
+		helmInfoLogF("%v signed by %v (%v)", verification.FileName, name, verification.FileHash)
+	}
+	return nil
+}
+
+func writeTempFile(pattern string, content []byte) (string, error) {
+	f, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
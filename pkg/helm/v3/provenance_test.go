@@ -0,0 +1,136 @@
+package v3
+
+import (
+	"bytes"
+	"github.com/coveros/genoa/pkg"
+	"golang.org/x/crypto/openpgp"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/provenance"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestKey creates a signing key and the public keyring holding it
+func newTestKey(t *testing.T, name string) (*openpgp.Entity, []byte) {
+	entity, errCreatingKey := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if errCreatingKey != nil {
+		t.Fatal(errCreatingKey)
+	}
+	keyring := &bytes.Buffer{}
+	if errSerializing := entity.Serialize(keyring); errSerializing != nil {
+		t.Fatal(errSerializing)
+	}
+	return entity, keyring.Bytes()
+}
+
+// newTestChart packages a minimal chart into dir
+func newTestChart(t *testing.T, dir, name, version string) string {
+	testChart := &chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: version}}
+	chartPath, errSaving := chartutil.Save(testChart, dir)
+	if errSaving != nil {
+		t.Fatal(errSaving)
+	}
+	return chartPath
+}
+
+// signTestChart writes the .prov file of the chart next to it, signed with the key
+func signTestChart(t *testing.T, chartPath string, key *openpgp.Entity) string {
+	signatory := &provenance.Signatory{Entity: key, KeyRing: openpgp.EntityList{key}}
+	signature, errSigning := signatory.ClearSign(chartPath)
+	if errSigning != nil {
+		t.Fatal(errSigning)
+	}
+	if errWriting := ioutil.WriteFile(chartPath+".prov", []byte(signature), 0644); errWriting != nil {
+		t.Fatal(errWriting)
+	}
+	return chartPath + ".prov"
+}
+
+func TestVerifyChartWithProvFile(t *testing.T) {
+	dir, errCreatingDir := ioutil.TempDir("", "genoa-provenance")
+	if errCreatingDir != nil {
+		t.Fatal(errCreatingDir)
+	}
+	defer os.RemoveAll(dir)
+
+	signingKey, signingKeyring := newTestKey(t, "chart signer")
+	_, otherKeyring := newTestKey(t, "someone else")
+
+	signedChart := newTestChart(t, filepath.Join(dir, "signed"), "jenkins", "1.0.0")
+	signedProv := signTestChart(t, signedChart, signingKey)
+
+	tamperedChart := newTestChart(t, filepath.Join(dir, "tampered"), "jenkins", "1.0.0")
+	tamperedProv := signTestChart(t, tamperedChart, signingKey)
+	if errTampering := ioutil.WriteFile(tamperedChart, []byte("not the chart that was signed"), 0644); errTampering != nil {
+		t.Fatal(errTampering)
+	}
+
+	tests := []struct {
+		name     string
+		chart    string
+		prov     string
+		keyrings [][]byte
+		wantErr  bool
+	}{
+		{name: "valid signature", chart: signedChart, prov: signedProv, keyrings: [][]byte{signingKeyring}},
+		{name: "valid signature in every keyring", chart: signedChart, prov: signedProv, keyrings: [][]byte{signingKeyring, signingKeyring}},
+		{name: "signed by a key that is not in the keyring", chart: signedChart, prov: signedProv, keyrings: [][]byte{otherKeyring}, wantErr: true},
+		{name: "signed by a key missing from one keyring", chart: signedChart, prov: signedProv, keyrings: [][]byte{signingKeyring, otherKeyring}, wantErr: true},
+		{name: "chart changed after signing", chart: tamperedChart, prov: tamperedProv, keyrings: [][]byte{signingKeyring}, wantErr: true},
+		{name: "missing prov file", chart: signedChart, prov: filepath.Join(dir, "missing.prov"), keyrings: [][]byte{signingKeyring}, wantErr: true},
+		{name: "not a keyring", chart: signedChart, prov: signedProv, keyrings: [][]byte{[]byte("not a keyring")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChartWithProvFile(tt.chart, tt.prov, tt.keyrings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyChartWithProvFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := err.(pkg.ErrorChartVerificationFailed); err != nil && !ok {
+				t.Errorf("verifyChartWithProvFile() error = %T, want ErrorChartVerificationFailed", err)
+			}
+		})
+	}
+}
+
+func TestVerifyChart(t *testing.T) {
+	dir, errCreatingDir := ioutil.TempDir("", "genoa-provenance")
+	if errCreatingDir != nil {
+		t.Fatal(errCreatingDir)
+	}
+	defer os.RemoveAll(dir)
+
+	signingKey, signingKeyring := newTestKey(t, "chart signer")
+	signedChart := newTestChart(t, filepath.Join(dir, "signed"), "jenkins", "1.0.0")
+	signTestChart(t, signedChart, signingKey)
+	unsignedChart := newTestChart(t, filepath.Join(dir, "unsigned"), "jenkins", "1.0.0")
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		chart   string
+		url     string
+		wantErr bool
+	}{
+		{name: "prov file next to the chart", chart: signedChart, url: server.URL + "/signed/jenkins-1.0.0.tgz"},
+		{name: "repo has no prov file", chart: unsignedChart, url: server.URL + "/unsigned/jenkins-1.0.0.tgz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChart(server.Client(), tt.chart, tt.url, "", "", [][]byte{signingKeyring})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyChart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := err.(pkg.ErrorChartVerificationFailed); err != nil && !ok {
+				t.Errorf("verifyChart() error = %T, want ErrorChartVerificationFailed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package v3
+
+import (
+	"bytes"
+	"fmt"
+	"helm.sh/helm/v3/pkg/release"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// UnreadyResource is a workload of a helm release that is not ready yet
+type UnreadyResource struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Reason    string
+	// Failed is set when the resource will not become ready without a change, e.g. a failed Job or a Deployment
+	// past its progress deadline
+	Failed bool
+}
+
+func (u UnreadyResource) String() string {
+	return fmt.Sprintf("%s %s/%s %s", u.Kind, u.Namespace, u.Name, u.Reason)
+}
+
+// CheckReadiness looks up the Deployments, StatefulSets, Jobs and PersistentVolumeClaims of the release manifest
+// and returns how many of them there are along with the ones that are not ready, without waiting for them.
+func (h *HelmV3) CheckReadiness(releaseInfo *release.Release) (int, []UnreadyResource, error) {
+	resources, errBuilding := h.actionConfig.KubeClient.Build(bytes.NewBufferString(releaseInfo.Manifest), false)
+	if errBuilding != nil {
+		return 0, nil, errBuilding
+	}
+
+	total := 0
+	var unready []UnreadyResource
+	for _, info := range resources {
+		kind := info.Mapping.GroupVersionKind.Kind
+		if !readinessChecked(kind) {
+			continue
+		}
+		total++
+		unreadyResource := UnreadyResource{Kind: kind, Namespace: info.Namespace, Name: info.Name}
+
+		liveObject, errGettingLive := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name, false)
+		if errGettingLive != nil {
+			if apiErrors.IsNotFound(errGettingLive) {
+				unreadyResource.Reason = "is missing"
+				unready = append(unready, unreadyResource)
+				continue
+			}
+			return 0, nil, errGettingLive
+		}
+		live, errConverting := runtime.DefaultUnstructuredConverter.ToUnstructured(liveObject)
+		if errConverting != nil {
+			return 0, nil, errConverting
+		}
+		if ready, failed, reason := resourceReady(kind, live); !ready {
+			unreadyResource.Reason, unreadyResource.Failed = reason, failed
+			unready = append(unready, unreadyResource)
+		}
+	}
+	return total, unready, nil
+}
+
+func readinessChecked(kind string) bool {
+	switch kind {
+	case "Deployment", "StatefulSet", "Job", "PersistentVolumeClaim":
+		return true
+	}
+	return false
+}
+
+// resourceReady decides whether a live object is ready, whether it failed for good and why not
+func resourceReady(kind string, obj map[string]interface{}) (bool, bool, string) {
+	generation, _, _ := unstructured.NestedInt64(obj, "metadata", "generation")
+	observedGeneration, _, _ := unstructured.NestedInt64(obj, "status", "observedGeneration")
+
+	switch kind {
+	case "Deployment":
+		replicas := nestedInt64Or(obj, 1, "spec", "replicas")
+		if observedGeneration < generation {
+			return false, false, "has not observed its latest spec"
+		}
+		conditions, _, _ := unstructured.NestedSlice(obj, "status", "conditions")
+		for _, c := range conditions {
+			condition, _ := c.(map[string]interface{})
+			if condition["type"] == "Progressing" && condition["status"] == "False" && condition["reason"] == "ProgressDeadlineExceeded" {
+				return false, true, fmt.Sprintf("failed: %v", condition["message"])
+			}
+		}
+		if updated, _, _ := unstructured.NestedInt64(obj, "status", "updatedReplicas"); updated < replicas {
+			return false, false, fmt.Sprintf("has %d of %d replicas updated", updated, replicas)
+		}
+		if available, _, _ := unstructured.NestedInt64(obj, "status", "availableReplicas"); available < replicas {
+			return false, false, fmt.Sprintf("has %d of %d replicas available", available, replicas)
+		}
+	case "StatefulSet":
+		replicas := nestedInt64Or(obj, 1, "spec", "replicas")
+		if observedGeneration < generation {
+			return false, false, "has not observed its latest spec"
+		}
+		if readyReplicas, _, _ := unstructured.NestedInt64(obj, "status", "readyReplicas"); readyReplicas < replicas {
+			return false, false, fmt.Sprintf("has %d of %d replicas ready", readyReplicas, replicas)
+		}
+		strategy, _, _ := unstructured.NestedString(obj, "spec", "updateStrategy", "type")
+		currentRevision, _, _ := unstructured.NestedString(obj, "status", "currentRevision")
+		updateRevision, _, _ := unstructured.NestedString(obj, "status", "updateRevision")
+		if strategy != "OnDelete" && currentRevision != updateRevision {
+			return false, false, "is rolling out a new revision"
+		}
+	case "Job":
+		conditions, _, _ := unstructured.NestedSlice(obj, "status", "conditions")
+		for _, c := range conditions {
+			condition, _ := c.(map[string]interface{})
+			if condition["type"] == "Failed" && condition["status"] == "True" {
+				return false, true, fmt.Sprintf("failed: %v", condition["message"])
+			}
+		}
+		completions := nestedInt64Or(obj, 1, "spec", "completions")
+		if succeeded, _, _ := unstructured.NestedInt64(obj, "status", "succeeded"); succeeded < completions {
+			return false, false, fmt.Sprintf("has %d of %d completions", succeeded, completions)
+		}
+	case "PersistentVolumeClaim":
+		if phase, _, _ := unstructured.NestedString(obj, "status", "phase"); phase != "Bound" {
+			return false, false, fmt.Sprintf("is %v", phase)
+		}
+	}
+	return true, false, ""
+}
+
+func nestedInt64Or(obj map[string]interface{}, defaultValue int64, fields ...string) int64 {
+	if value, found, err := unstructured.NestedInt64(obj, fields...); found && err == nil {
+		return value
+	}
+	return defaultValue
+}
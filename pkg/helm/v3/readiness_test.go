@@ -0,0 +1,109 @@
+package v3
+
+import "testing"
+
+func Test_resourceReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		kind       string
+		obj        map[string]interface{}
+		wantReady  bool
+		wantFailed bool
+	}{
+		{
+			name: "deployment available",
+			kind: "Deployment",
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"generation": int64(2)},
+				"spec":     map[string]interface{}{"replicas": int64(3)},
+				"status":   map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(3), "availableReplicas": int64(3)},
+			},
+			wantReady: true,
+		},
+		{
+			name: "deployment rolling out",
+			kind: "Deployment",
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"generation": int64(2)},
+				"spec":     map[string]interface{}{"replicas": int64(3)},
+				"status":   map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(1), "availableReplicas": int64(3)},
+			},
+		},
+		{
+			name: "deployment past its progress deadline",
+			kind: "Deployment",
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"generation": int64(2)},
+				"spec":     map[string]interface{}{"replicas": int64(1)},
+				"status": map[string]interface{}{
+					"observedGeneration": int64(2),
+					"updatedReplicas":    int64(1),
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Available", "status": "False", "reason": "MinimumReplicasUnavailable"},
+						map[string]interface{}{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded",
+							"message": `ReplicaSet "web-5d4f" has timed out progressing.`},
+					},
+				},
+			},
+			wantFailed: true,
+		},
+		{
+			name: "deployment not observed",
+			kind: "Deployment",
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"generation": int64(3)},
+				"status":   map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(1), "availableReplicas": int64(1)},
+			},
+		},
+		{
+			name: "statefulset on the new revision",
+			kind: "StatefulSet",
+			obj: map[string]interface{}{
+				"spec":   map[string]interface{}{"replicas": int64(2)},
+				"status": map[string]interface{}{"readyReplicas": int64(2), "currentRevision": "web-2", "updateRevision": "web-2"},
+			},
+			wantReady: true,
+		},
+		{
+			name: "statefulset rolling out",
+			kind: "StatefulSet",
+			obj: map[string]interface{}{
+				"spec":   map[string]interface{}{"replicas": int64(2)},
+				"status": map[string]interface{}{"readyReplicas": int64(2), "currentRevision": "web-1", "updateRevision": "web-2"},
+			},
+		},
+		{
+			name:      "job complete",
+			kind:      "Job",
+			obj:       map[string]interface{}{"status": map[string]interface{}{"succeeded": int64(1)}},
+			wantReady: true,
+		},
+		{
+			name: "job failed",
+			kind: "Job",
+			obj: map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{
+				map[string]interface{}{"type": "Failed", "status": "True", "message": "BackoffLimitExceeded"},
+			}}},
+			wantFailed: true,
+		},
+		{
+			name:      "pvc bound",
+			kind:      "PersistentVolumeClaim",
+			obj:       map[string]interface{}{"status": map[string]interface{}{"phase": "Bound"}},
+			wantReady: true,
+		},
+		{
+			name: "pvc pending",
+			kind: "PersistentVolumeClaim",
+			obj:  map[string]interface{}{"status": map[string]interface{}{"phase": "Pending"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, failed, reason := resourceReady(tt.kind, tt.obj)
+			if ready != tt.wantReady || failed != tt.wantFailed {
+				t.Errorf("resourceReady() = %v, %v, %q, want %v, %v", ready, failed, reason, tt.wantReady, tt.wantFailed)
+			}
+		})
+	}
+}
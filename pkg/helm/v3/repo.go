@@ -4,41 +4,60 @@ import (
 	"fmt"
 	"github.com/coveros/genoa/pkg"
 	"github.com/coveros/genoa/pkg/utils"
-	"helm.sh/helm/v3/pkg/getter"
+	"github.com/ghodss/yaml"
 	"helm.sh/helm/v3/pkg/repo"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/types"
 	"os"
 	"sort"
 	"strings"
 )
 
 func (h *HelmV3) GetRepoUrlFromRepoConfig(repoAliasName string) (string, string, string, error) {
-	repoFile, errLoadingRepoFile := repo.LoadFile(h.settings.RepositoryConfig)
+	repoEntry, errLookingUpRepo := h.GetRepoEntryFromRepoConfig(repoAliasName)
+	if errLookingUpRepo != nil {
+		return "", "", "", errLookingUpRepo
+	}
+	return utils.TrimSuffix(repoEntry.URL, "/"), repoEntry.Username, repoEntry.Password, nil
+}
+
+// GetRepoEntryFromRepoConfig returns the full helm repo entry, including its TLS settings
+func (h *HelmV3) GetRepoEntryFromRepoConfig(repoAliasName string) (*repo.Entry, error) {
+	repoFile, errLoadingRepoFile := h.getRepoFile()
 	if errLoadingRepoFile != nil {
-		return "", "", "", errLoadingRepoFile
+		return nil, errLoadingRepoFile
 	}
 
 	for _, eachRepo := range repoFile.Repositories {
 		if strings.ToLower(eachRepo.Name) == strings.ToLower(repoAliasName) {
-			return utils.TrimSuffix(eachRepo.URL, "/"), eachRepo.Username, eachRepo.Password, nil
+			return eachRepo, nil
 		}
 	}
 
-	return "", "", "", pkg.ErrorHelmRepoNotFoundInRepoConfig{Message: fmt.Sprintf("%v repo not found repo config, please add it first", repoAliasName)}
+	return nil, pkg.ErrorHelmRepoNotFoundInRepoConfig{Message: fmt.Sprintf("%v repo not found repo config, please add it first", repoAliasName)}
 }
 
 func (h *HelmV3) FindDownloadUrlFromCacheFile(repoCacheFile *repo.IndexFile, chartName, chartVersion string) (string, error) {
+	entry, err := findChartVersionFromCacheFile(repoCacheFile, chartName, chartVersion)
+	if err != nil {
+		return "", err
+	}
+	return entry.URLs[0], nil
+}
+
+func findChartVersionFromCacheFile(repoCacheFile *repo.IndexFile, chartName, chartVersion string) (*repo.ChartVersion, error) {
 	if chartEntries, chartFound := repoCacheFile.Entries[chartName]; chartFound {
 		sort.Slice(chartEntries, func(i, j int) bool {
 			return chartEntries[i].Version < chartEntries[j].Version
 		})
 		idx, err := getIdxOfChartVersionFromChartEntries(chartEntries, chartVersion, 0, len(chartEntries)-1)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		return chartEntries[idx].URLs[0], nil
+		return chartEntries[idx], nil
 	}
-	return "", pkg.ErrorHelmRepoNeedsRefresh{Message: fmt.Sprintf("%v-%v chart not found in repo index, a refresh might help", chartName, chartVersion)}
+	return nil, pkg.ErrorHelmRepoNeedsRefresh{Message: fmt.Sprintf("%v-%v chart not found in repo index, a refresh might help", chartName, chartVersion)}
 }
 
 func AddReposFromFile(customRepoFile string) error {
@@ -58,7 +77,57 @@ func AddReposFromFile(customRepoFile string) error {
 	return repoFile.WriteFile(DefaultEnvSettings().RepositoryConfig, os.ModePerm)
 }
 
-func (h *HelmV3) RefreshRepoIndex(repoAlias string) error {
+// RepoOptions are genoa specific settings for a helm repo. They live alongside the helm repo entries
+// in the custom helm repos file, helm itself ignores them.
+type RepoOptions struct {
+	Name string `json:"name"`
+	// VerifyProvenance requires every chart pulled from this repo to have a valid .prov file
+	VerifyProvenance bool `json:"verifyProvenance"`
+	// KeyringSecret is the "namespace/name" of the secret holding the public keyring charts are verified against.
+	// The namespace is required, a Release namespace is not trusted to hold the keyring of a repo.
+	KeyringSecret string `json:"keyringSecret"`
+	// Proxy is the url of the proxy to reach this repo through, defaults to the HTTP(S)_PROXY env vars
+	Proxy string `json:"proxy"`
+	// Timeout is a duration like "30s" for each request to this repo, defaults to 2m
+	Timeout string `json:"timeout"`
+}
+
+// LoadRepoOptionsFromFile reads the genoa specific repo settings from a custom helm repos file, keyed by lower case repo name
+func LoadRepoOptionsFromFile(customRepoFile string) (map[string]RepoOptions, error) {
+	raw, errReadingFile := ioutil.ReadFile(customRepoFile)
+	if errReadingFile != nil {
+		return nil, errReadingFile
+	}
+
+	customRepos := struct {
+		Repositories []RepoOptions `json:"repositories"`
+	}{}
+	if errParsing := yaml.Unmarshal(raw, &customRepos); errParsing != nil {
+		return nil, errParsing
+	}
+
+	repoOptions := map[string]RepoOptions{}
+	for _, eachRepo := range customRepos.Repositories {
+		if eachRepo.KeyringSecret != "" {
+			if _, errParsing := eachRepo.KeyringSecretName(); errParsing != nil {
+				return nil, errParsing
+			}
+		}
+		repoOptions[strings.ToLower(eachRepo.Name)] = eachRepo
+	}
+	return repoOptions, nil
+}
+
+// KeyringSecretName splits KeyringSecret into its namespace and name, both must be set
+func (o RepoOptions) KeyringSecretName() (types.NamespacedName, error) {
+	nsAndName := strings.SplitN(o.KeyringSecret, "/", 2)
+	if len(nsAndName) != 2 || nsAndName[0] == "" || nsAndName[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("%v repo keyringSecret %q must be <namespace>/<name>", o.Name, o.KeyringSecret)
+	}
+	return types.NamespacedName{Namespace: nsAndName[0], Name: nsAndName[1]}, nil
+}
+
+func (h *HelmV3) RefreshRepoIndex(repoAlias string, repoOpts RepoOptions) error {
 	repoFile, errGettingRepoFile := h.getRepoFile()
 	if errGettingRepoFile != nil {
 		return errGettingRepoFile
@@ -66,7 +135,12 @@ func (h *HelmV3) RefreshRepoIndex(repoAlias string) error {
 
 	for _, repoEntry := range repoFile.Repositories {
 		if repoEntry.Name == repoAlias {
-			newChartRepo, err := repo.NewChartRepository(repoEntry, getter.All(h.settings))
+			client, errCreatingClient := newRepoHTTPClient(repoEntry, repoOpts)
+			if errCreatingClient != nil {
+				return errCreatingClient
+			}
+			indexGetter := repoGetter{client: client, username: repoEntry.Username, password: repoEntry.Password}
+			newChartRepo, err := repo.NewChartRepository(repoEntry, indexGetter.providers())
 			if err != nil {
 				return err
 			}
@@ -3,6 +3,10 @@ package v3
 import (
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/repo"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -400,3 +404,57 @@ func Test_getIdxOfChartVersionFromChartEntries(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadRepoOptionsFromFile(t *testing.T) {
+	dir, errCreatingDir := ioutil.TempDir("", "genoa-repos")
+	if errCreatingDir != nil {
+		t.Fatal(errCreatingDir)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name        string
+		repos       string
+		wantKeyring types.NamespacedName
+		wantErr     bool
+	}{
+		{
+			name:        "keyring secret with a namespace",
+			repos:       "repositories:\n- name: Stable\n  verifyProvenance: true\n  keyringSecret: genoa/helm-keyring\n",
+			wantKeyring: types.NamespacedName{Namespace: "genoa", Name: "helm-keyring"},
+		},
+		{
+			name:  "no keyring secret",
+			repos: "repositories:\n- name: stable\n",
+		},
+		{
+			name:    "keyring secret without a namespace",
+			repos:   "repositories:\n- name: stable\n  verifyProvenance: true\n  keyringSecret: helm-keyring\n",
+			wantErr: true,
+		},
+		{
+			name:    "keyring secret with an empty namespace",
+			repos:   "repositories:\n- name: stable\n  verifyProvenance: true\n  keyringSecret: /helm-keyring\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoFile := filepath.Join(dir, "repos.yaml")
+			if errWriting := ioutil.WriteFile(repoFile, []byte(tt.repos), 0644); errWriting != nil {
+				t.Fatal(errWriting)
+			}
+			got, err := LoadRepoOptionsFromFile(repoFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadRepoOptionsFromFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr || tt.wantKeyring.Name == "" {
+				return
+			}
+			keyring, errParsing := got["stable"].KeyringSecretName()
+			if errParsing != nil || keyring != tt.wantKeyring {
+				t.Errorf("KeyringSecretName() = %v, %v, want %v", keyring, errParsing, tt.wantKeyring)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package v3
 
 import (
 	"helm.sh/helm/v3/pkg/action"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,13 @@ func (h *HelmV3) RollbackToRevision(releaseName string, rollbackOptions Rollback
 	return rollBackClient.Run(releaseName)
 }
 
+// IsImmutableFieldError reports whether the api server refused to patch an object because a field like
+// a Service clusterIP or a Deployment selector cannot change, e.g.
+// Service "jenkins" is invalid: spec.clusterIP: Invalid value: "": field is immutable
+func IsImmutableFieldError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "field is immutable")
+}
+
 func (r RollbackToRevisionOptions) withRollbackOptions(rollbackClient *action.Rollback) {
 	rollbackClient.Version = r.ToRevision
 	rollbackClient.Wait = r.Wait
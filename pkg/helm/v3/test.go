@@ -0,0 +1,55 @@
+package v3
+
+import (
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"sort"
+	"time"
+)
+
+// TestResult is the outcome of a single helm test pod
+type TestResult struct {
+	Name        string
+	Phase       string
+	StartedAt   time.Time
+	CompletedAt time.Time
+}
+
+// TestRelease runs the helm test hooks of the latest revision of a release, like helm test.
+// The results are returned even when a test fails.
+func (h *HelmV3) TestRelease(releaseName string, timeout time.Duration) ([]TestResult, error) {
+	testAction := action.NewReleaseTesting(h.actionConfig)
+	testAction.Timeout = timeout
+	testAction.Namespace = h.namespace
+	testedRelease, errTesting := testAction.Run(releaseName)
+	return testResults(testedRelease), errTesting
+}
+
+func testResults(releaseInfo *release.Release) []TestResult {
+	if releaseInfo == nil {
+		return nil
+	}
+	var results []TestResult
+	for _, hook := range releaseInfo.Hooks {
+		if !isTestHook(hook) {
+			continue
+		}
+		results = append(results, TestResult{
+			Name:        hook.Name,
+			Phase:       string(hook.LastRun.Phase),
+			StartedAt:   hook.LastRun.StartedAt.Time,
+			CompletedAt: hook.LastRun.CompletedAt.Time,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+func isTestHook(hook *release.Hook) bool {
+	for _, event := range hook.Events {
+		if event == release.HookTest {
+			return true
+		}
+	}
+	return false
+}
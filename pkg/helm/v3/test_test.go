@@ -0,0 +1,25 @@
+package v3
+
+import (
+	"helm.sh/helm/v3/pkg/release"
+	"reflect"
+	"testing"
+)
+
+func Test_testResults(t *testing.T) {
+	releaseInfo := &release.Release{Hooks: []*release.Hook{
+		{Name: "jenkins-ui-test", Events: []release.HookEvent{release.HookTest}, LastRun: release.HookExecution{Phase: release.HookPhaseFailed}},
+		{Name: "jenkins-pre-upgrade", Events: []release.HookEvent{release.HookPreUpgrade}, LastRun: release.HookExecution{Phase: release.HookPhaseSucceeded}},
+		{Name: "jenkins-api-test", Events: []release.HookEvent{release.HookPostInstall, release.HookTest}, LastRun: release.HookExecution{Phase: release.HookPhaseSucceeded}},
+	}}
+	want := []TestResult{
+		{Name: "jenkins-api-test", Phase: "Succeeded"},
+		{Name: "jenkins-ui-test", Phase: "Failed"},
+	}
+	if got := testResults(releaseInfo); !reflect.DeepEqual(got, want) {
+		t.Errorf("testResults() = %+v, want %+v", got, want)
+	}
+	if got := testResults(nil); got != nil {
+		t.Errorf("testResults(nil) = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,176 @@
+package v3
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/coveros/genoa/pkg/utils"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRepoTimeout = 2 * time.Minute
+
+// repoClientKey is everything a repo http client is built from. The TLS files are identified by their size and
+// modification time so a rotated certificate gets a new client.
+type repoClientKey struct {
+	repo     string
+	insecure bool
+	caFile   string
+	certFile string
+	keyFile  string
+	files    string
+	proxy    string
+	timeout  string
+}
+
+var (
+	repoClientsLock sync.Mutex
+	// repoClients keeps one client per repo and settings so connections to a repo are reused across pulls
+	repoClients = map[repoClientKey]*http.Client{}
+)
+
+func newRepoClientKey(entry *repo.Entry, opts RepoOptions) repoClientKey {
+	var files []string
+	for _, file := range []string{entry.CAFile, entry.CertFile, entry.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if info, errStating := os.Stat(file); errStating == nil {
+			files = append(files, fmt.Sprintf("%v:%v", info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	return repoClientKey{
+		repo:     entry.Name,
+		insecure: entry.InsecureSkipTLSverify,
+		caFile:   entry.CAFile,
+		certFile: entry.CertFile,
+		keyFile:  entry.KeyFile,
+		files:    strings.Join(files, ","),
+		proxy:    opts.Proxy,
+		timeout:  opts.Timeout,
+	}
+}
+
+// newRepoHTTPClient returns the http client used for index refreshes, chart and provenance downloads of a repo,
+// built once per repo and settings. A client built from older settings of the repo is dropped.
+func newRepoHTTPClient(entry *repo.Entry, opts RepoOptions) (*http.Client, error) {
+	key := newRepoClientKey(entry, opts)
+	repoClientsLock.Lock()
+	defer repoClientsLock.Unlock()
+	if client, ok := repoClients[key]; ok {
+		return client, nil
+	}
+
+	client, errBuildingClient := buildRepoHTTPClient(entry, opts)
+	if errBuildingClient != nil {
+		return nil, errBuildingClient
+	}
+	for oldKey, oldClient := range repoClients {
+		if oldKey.repo == key.repo {
+			oldClient.CloseIdleConnections()
+			delete(repoClients, oldKey)
+		}
+	}
+	repoClients[key] = client
+	return client, nil
+}
+
+// buildRepoHTTPClient builds the http client of a repo.
+// TLS settings come from the helm repo entry, proxy and timeout from the genoa repo options.
+func buildRepoHTTPClient(entry *repo.Entry, opts RepoOptions) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: entry.InsecureSkipTLSverify}
+	if entry.InsecureSkipTLSverify {
+		helmInfoLogF("TLS verification is disabled for %v repo", entry.Name)
+	}
+
+	if entry.CAFile != "" {
+		caBundle, errReadingCA := ioutil.ReadFile(entry.CAFile)
+		if errReadingCA != nil {
+			return nil, errReadingCA
+		}
+		// keep trusting public CAs, the bundle only adds to them
+		rootCAs, errLoadingSystemCAs := x509.SystemCertPool()
+		if errLoadingSystemCAs != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no certificates found in %v caFile %v", entry.Name, entry.CAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if entry.CertFile != "" || entry.KeyFile != "" {
+		if entry.CertFile == "" || entry.KeyFile == "" {
+			return nil, fmt.Errorf("%v repo needs both certFile and keyFile for client certificates", entry.Name)
+		}
+		clientCert, errLoadingCert := tls.LoadX509KeyPair(entry.CertFile, entry.KeyFile)
+		if errLoadingCert != nil {
+			return nil, errLoadingCert
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		proxyUrl, errParsingProxy := url.Parse(opts.Proxy)
+		if errParsingProxy != nil {
+			return nil, errParsingProxy
+		}
+		proxy = http.ProxyURL(proxyUrl)
+	}
+
+	timeout := defaultRepoTimeout
+	if opts.Timeout != "" {
+		parsedTimeout, errParsingTimeout := time.ParseDuration(opts.Timeout)
+		if errParsingTimeout != nil {
+			return nil, errParsingTimeout
+		}
+		timeout = parsedTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// repoGetter lets helm download repo indexes with the same http client genoa uses for charts
+type repoGetter struct {
+	client   *http.Client
+	username string
+	password string
+}
+
+func (g repoGetter) Get(href string, _ ...getter.Option) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	err := utils.RetryTransient(func() error {
+		buf.Reset()
+		resp, err := utils.HTTPGet(g.client, href, g.username, g.password)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = io.Copy(buf, resp.Body)
+		return err
+	})
+	return buf, err
+}
+
+func (g repoGetter) providers() getter.Providers {
+	return getter.Providers{{
+		Schemes: []string{"http", "https"},
+		New: func(_ ...getter.Option) (getter.Getter, error) {
+			return g, nil
+		},
+	}}
+}
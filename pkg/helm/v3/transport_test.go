@@ -0,0 +1,101 @@
+package v3
+
+import (
+	"encoding/pem"
+	"helm.sh/helm/v3/pkg/repo"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_newRepoHTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("apiVersion: v1"))
+	}))
+	defer server.Close()
+
+	caFile, _ := ioutil.TempFile("", "ca-*.pem")
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	tests := []struct {
+		name    string
+		entry   *repo.Entry
+		opts    RepoOptions
+		wantErr bool
+	}{
+		{
+			name:    "untrusted server certificate",
+			entry:   &repo.Entry{Name: "private", URL: server.URL},
+			wantErr: true,
+		},
+		{
+			name:  "trusted with repo caFile",
+			entry: &repo.Entry{Name: "private", URL: server.URL, CAFile: caFile.Name()},
+		},
+		{
+			name:  "explicit insecure skip verify",
+			entry: &repo.Entry{Name: "private", URL: server.URL, InsecureSkipTLSverify: true},
+		},
+		{
+			name:    "cert without key",
+			entry:   &repo.Entry{Name: "private", URL: server.URL, CertFile: caFile.Name()},
+			wantErr: true,
+		},
+		{
+			name:    "invalid timeout",
+			entry:   &repo.Entry{Name: "private", URL: server.URL, CAFile: caFile.Name()},
+			opts:    RepoOptions{Timeout: "soon"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newRepoHTTPClient(tt.entry, tt.opts)
+			if err == nil {
+				_, err = repoGetter{client: client}.Get(server.URL + "/index.yaml")
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newRepoHTTPClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_newRepoHTTPClientIsReused(t *testing.T) {
+	caFile, _ := ioutil.TempFile("", "ca-*.pem")
+	defer os.Remove(caFile.Name())
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	entry := &repo.Entry{Name: "reused", URL: server.URL, CAFile: caFile.Name()}
+	first, err := newRepoHTTPClient(entry, RepoOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := newRepoHTTPClient(&repo.Entry{Name: "reused", URL: server.URL, CAFile: caFile.Name()}, RepoOptions{}); again != first {
+		t.Errorf("newRepoHTTPClient() built a new client for the same repo settings")
+	}
+	if other, _ := newRepoHTTPClient(&repo.Entry{Name: "other", URL: server.URL, CAFile: caFile.Name()}, RepoOptions{}); other == first {
+		t.Errorf("newRepoHTTPClient() shared a client between repos")
+	}
+
+	withTimeout, _ := newRepoHTTPClient(entry, RepoOptions{Timeout: "10s"})
+	if withTimeout == first {
+		t.Errorf("newRepoHTTPClient() reused a client after the repo timeout changed")
+	}
+
+	rotated := time.Now().Add(time.Hour)
+	if err := os.Chtimes(caFile.Name(), rotated, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if afterRotation, _ := newRepoHTTPClient(entry, RepoOptions{Timeout: "10s"}); afterRotation == withTimeout {
+		t.Errorf("newRepoHTTPClient() reused a client after the caFile changed")
+	}
+}
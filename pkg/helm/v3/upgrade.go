@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/release"
 	"os"
 	"time"
@@ -21,6 +22,7 @@ type UpgradeOptions struct {
 	DisableOpenAPIValidation bool
 	CleanupOnFail            bool
 	Force                    bool
+	PostRenderer             postrender.PostRenderer
 }
 
 func (h *HelmV3) UpgradeRelease(chartPath string, opts UpgradeOptions, values map[string]interface{}) (*release.Release, error) {
@@ -53,4 +55,5 @@ func (u UpgradeOptions) setUpgradeOptions(upgradeAction *action.Upgrade) {
 	upgradeAction.Timeout = u.Timeout
 	upgradeAction.CleanupOnFail = u.CleanupOnFail
 	upgradeAction.Force = u.Force
+	upgradeAction.PostRenderer = u.PostRenderer
 }
@@ -0,0 +1,170 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02T15:04"
+	// maxSteps bounds the search for the next allowed time when windows and blackouts keep overlapping
+	maxSteps = 1000
+)
+
+// Window opens every time Cron fires and stays open for Duration, e.g. "0 9 * * 2-4" and "7h"
+type Window struct {
+	Cron     string
+	Duration string
+}
+
+// Blackout forbids changes from Start until End, given as 2006-01-02, 2006-01-02T15:04 or RFC3339.
+// A date only End includes that whole day.
+type Blackout struct {
+	Start  string
+	End    string
+	Reason string
+}
+
+// Schedule decides when changes may be rolled out
+type Schedule struct {
+	location  *time.Location
+	windows   []window
+	blackouts []blackout
+}
+
+type window struct {
+	cron     *cronExpression
+	duration time.Duration
+}
+
+type blackout struct {
+	start  time.Time
+	end    time.Time
+	reason string
+}
+
+// New parses a schedule in the given timezone, UTC when empty.
+// Without windows changes are allowed at any time outside the blackouts.
+func New(timezone string, windows []Window, blackouts []Blackout) (*Schedule, error) {
+	location, errLoadingLocation := time.LoadLocation(timezone)
+	if errLoadingLocation != nil {
+		return nil, errLoadingLocation
+	}
+	s := &Schedule{location: location}
+
+	for _, w := range windows {
+		cron, errParsingCron := parseCron(w.Cron)
+		if errParsingCron != nil {
+			return nil, errParsingCron
+		}
+		duration, errParsingDuration := time.ParseDuration(w.Duration)
+		if errParsingDuration != nil {
+			return nil, errParsingDuration
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("window %q needs a positive duration", w.Cron)
+		}
+		s.windows = append(s.windows, window{cron: cron, duration: duration})
+	}
+
+	for _, b := range blackouts {
+		start, _, errParsingStart := parseTime(b.Start, location)
+		if errParsingStart != nil {
+			return nil, errParsingStart
+		}
+		end, dateOnly, errParsingEnd := parseTime(b.End, location)
+		if errParsingEnd != nil {
+			return nil, errParsingEnd
+		}
+		if dateOnly {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("blackout %v ends before it starts", b.Start)
+		}
+		s.blackouts = append(s.blackouts, blackout{start: start, end: end, reason: b.Reason})
+	}
+	return s, nil
+}
+
+func parseTime(value string, location *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(dateLayout, value, location); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.ParseInLocation(dateTimeLayout, value, location); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%q is not a date, use 2006-01-02, 2006-01-02T15:04 or RFC3339", value)
+	}
+	return t, false, nil
+}
+
+// Allowed reports whether changes may be rolled out at t, and why not when they may not
+func (s *Schedule) Allowed(t time.Time) (bool, string) {
+	t = t.In(s.location)
+	if b := s.blackoutAt(t); b != nil {
+		if b.reason != "" {
+			return false, fmt.Sprintf("blackout until %v: %v", b.end.Format(time.RFC3339), b.reason)
+		}
+		return false, fmt.Sprintf("blackout until %v", b.end.Format(time.RFC3339))
+	}
+	if len(s.windows) > 0 && !s.inWindow(t) {
+		return false, "outside of the deployment windows"
+	}
+	return true, ""
+}
+
+// NextAllowed returns the first time at or after t that changes may be rolled out,
+// false when the schedule never allows changes again
+func (s *Schedule) NextAllowed(t time.Time) (time.Time, bool) {
+	t = t.In(s.location)
+	for i := 0; i < maxSteps; i++ {
+		if b := s.blackoutAt(t); b != nil {
+			t = b.end.In(s.location)
+			continue
+		}
+		if len(s.windows) > 0 && !s.inWindow(t) {
+			opening, found := s.nextWindowOpening(t)
+			if !found {
+				return time.Time{}, false
+			}
+			t = opening
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func (s *Schedule) blackoutAt(t time.Time) *blackout {
+	for i := range s.blackouts {
+		if !t.Before(s.blackouts[i].start) && t.Before(s.blackouts[i].end) {
+			return &s.blackouts[i]
+		}
+	}
+	return nil
+}
+
+// inWindow looks for a window that opened within its duration before t
+func (s *Schedule) inWindow(t time.Time) bool {
+	for _, w := range s.windows {
+		if opened, found := w.cron.next(t.Add(-w.duration).Add(time.Second)); found && !opened.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schedule) nextWindowOpening(t time.Time) (time.Time, bool) {
+	var earliest time.Time
+	found := false
+	for _, w := range s.windows {
+		if opening, ok := w.cron.next(t); ok && (!found || opening.Before(earliest)) {
+			earliest, found = opening, true
+		}
+	}
+	return earliest, found
+}
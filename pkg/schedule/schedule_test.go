@@ -0,0 +1,104 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_NextAllowed(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	// tuesday to thursday 09:00-16:00 new york time, frozen over the holidays
+	officeHours := []Window{{Cron: "0 9 * * 2-4", Duration: "7h"}}
+	holidays := []Blackout{{Start: "2020-12-21", End: "2021-01-01", Reason: "holiday freeze"}}
+
+	tests := []struct {
+		name        string
+		windows     []Window
+		blackouts   []Blackout
+		now         time.Time
+		wantAllowed bool
+		wantNext    time.Time
+	}{
+		{
+			name:        "no windows or blackouts",
+			now:         time.Date(2020, 9, 5, 3, 0, 0, 0, newYork),
+			wantAllowed: true,
+			wantNext:    time.Date(2020, 9, 5, 3, 0, 0, 0, newYork),
+		},
+		{
+			name:        "inside a window",
+			windows:     officeHours,
+			now:         time.Date(2020, 9, 9, 15, 59, 0, 0, newYork),
+			wantAllowed: true,
+			wantNext:    time.Date(2020, 9, 9, 15, 59, 0, 0, newYork),
+		},
+		{
+			name:     "window just closed",
+			windows:  officeHours,
+			now:      time.Date(2020, 9, 9, 16, 0, 0, 0, newYork),
+			wantNext: time.Date(2020, 9, 10, 9, 0, 0, 0, newYork),
+		},
+		{
+			name:     "friday waits for tuesday",
+			windows:  officeHours,
+			now:      time.Date(2020, 9, 11, 10, 0, 0, 0, newYork),
+			wantNext: time.Date(2020, 9, 15, 9, 0, 0, 0, newYork),
+		},
+		{
+			name:     "utc time is converted to the schedule timezone",
+			windows:  officeHours,
+			now:      time.Date(2020, 9, 9, 12, 0, 0, 0, time.UTC),
+			wantNext: time.Date(2020, 9, 9, 9, 0, 0, 0, newYork),
+		},
+		{
+			name:      "blackout inside a window",
+			windows:   officeHours,
+			blackouts: holidays,
+			now:       time.Date(2020, 12, 22, 10, 0, 0, 0, newYork),
+			wantNext:  time.Date(2021, 1, 5, 9, 0, 0, 0, newYork),
+		},
+		{
+			name:      "blackout without windows ends after its last day",
+			blackouts: holidays,
+			now:       time.Date(2020, 12, 31, 23, 0, 0, 0, newYork),
+			wantNext:  time.Date(2021, 1, 2, 0, 0, 0, 0, newYork),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New("America/New_York", tt.windows, tt.blackouts)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if allowed, reason := s.Allowed(tt.now); allowed != tt.wantAllowed {
+				t.Errorf("Allowed() = %v (%v), want %v", allowed, reason, tt.wantAllowed)
+			}
+			next, found := s.NextAllowed(tt.now)
+			if !found || !next.Equal(tt.wantNext) {
+				t.Errorf("NextAllowed() = %v, %v, want %v", next, found, tt.wantNext)
+			}
+		})
+	}
+}
+
+func Test_parseCron(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    bool
+	}{
+		{expression: "0 9 * * 2-4"},
+		{expression: "*/15 9-17/2 1,15 * 0,7"},
+		{expression: "0 9 * *", wantErr: true},
+		{expression: "60 9 * * *", wantErr: true},
+		{expression: "0 9 * * 5-1", wantErr: true},
+		{expression: "0 9 * * MON", wantErr: true},
+		{expression: "*/0 9 * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			if _, err := parseCron(tt.expression); (err != nil) != tt.wantErr {
+				t.Errorf("parseCron() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
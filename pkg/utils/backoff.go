@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffDelay returns how long to wait after the given number of consecutive failures: base doubled for every
+// failure after the first, capped at max, plus up to jitterPercent of that at random so retries do not line up
+func BackoffDelay(base, max time.Duration, jitterPercent, failures int) time.Duration {
+	delay := base
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	if jitterPercent > 0 {
+		delay += time.Duration(rand.Int63n(int64(delay)*int64(jitterPercent)/100 + 1))
+	}
+	return delay
+}
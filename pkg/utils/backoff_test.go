@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		name          string
+		jitterPercent int
+		failures      int
+		wantMin       time.Duration
+		wantMax       time.Duration
+	}{
+		{name: "first failure waits base", failures: 1, wantMin: 10 * time.Second, wantMax: 10 * time.Second},
+		{name: "doubles per failure", failures: 4, wantMin: 80 * time.Second, wantMax: 80 * time.Second},
+		{name: "capped at max", failures: 30, wantMin: 10 * time.Minute, wantMax: 10 * time.Minute},
+		{name: "jitter adds up to its share", jitterPercent: 50, failures: 2, wantMin: 20 * time.Second, wantMax: 30 * time.Second},
+		{name: "jitter on top of max", jitterPercent: 10, failures: 30, wantMin: 10 * time.Minute, wantMax: 11 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				got := BackoffDelay(10*time.Second, 10*time.Minute, tt.jitterPercent, tt.failures)
+				if got < tt.wantMin || got > tt.wantMax {
+					t.Fatalf("BackoffDelay() = %v, want between %v and %v", got, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+// FindCycle walks a dependency graph depth first from start and returns the first path that leads back to start,
+// e.g. [a b c a], or nil when start is not part of a cycle. Cycles that do not include start are ignored.
+func FindCycle(start string, edges func(node string) ([]string, error)) ([]string, error) {
+	visited := map[string]bool{}
+	var path []string
+
+	var visit func(node string) (bool, error)
+	visit = func(node string) (bool, error) {
+		path = append(path, node)
+		next, err := edges(node)
+		if err != nil {
+			return false, err
+		}
+		for _, n := range next {
+			if n == start {
+				path = append(path, n)
+				return true, nil
+			}
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			found, err := visit(n)
+			if found || err != nil {
+				return found, err
+			}
+		}
+		path = path[:len(path)-1]
+		return false, nil
+	}
+
+	found, err := visit(start)
+	if !found || err != nil {
+		return nil, err
+	}
+	return path, nil
+}
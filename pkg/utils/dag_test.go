@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph map[string][]string
+		want  []string
+	}{
+		{
+			name:  "no dependencies",
+			graph: map[string][]string{},
+		},
+		{
+			name:  "diamond is not a cycle",
+			graph: map[string][]string{"app": {"api", "ui"}, "api": {"db"}, "ui": {"db"}},
+		},
+		{
+			name:  "depends on itself",
+			graph: map[string][]string{"app": {"app"}},
+			want:  []string{"app", "app"},
+		},
+		{
+			name:  "cycle through dependencies",
+			graph: map[string][]string{"app": {"cache", "api"}, "api": {"db"}, "db": {"app"}},
+			want:  []string{"app", "api", "db", "app"},
+		},
+		{
+			name:  "cycle not including start",
+			graph: map[string][]string{"app": {"api"}, "api": {"db"}, "db": {"api"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindCycle("app", func(node string) ([]string, error) {
+				return tt.graph[node], nil
+			})
+			if err != nil {
+				t.Fatalf("FindCycle() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindCycle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
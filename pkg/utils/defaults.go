@@ -1,10 +1,20 @@
 package utils
 
 const (
-	ReleaseFinalizer                = "coveros.apps.genoa"
+	ReleaseFinalizer = "coveros.apps.genoa"
+	// Deprecated: genoa only deletes namespaces labeled with NamespaceOwnedLabel, it no longer reads this annotation
 	AutoDeleteNamespaceAnnotation   = ReleaseFinalizer + "/autoDeleteNamespace"
 	GitBranchToFollowAnnotation     = ReleaseFinalizer + "/follow-git-branch"
 	SlackChannelIDAnnotation        = ReleaseFinalizer + "/notification-channel-id"
+	DeletionPolicyAnnotation        = ReleaseFinalizer + "/deletion-policy"
+	DeletionPolicyCascade           = "cascade"
+	SuspendAnnotation               = ReleaseFinalizer + "/suspend"
+	DeploymentScheduleAnnotation    = ReleaseFinalizer + "/deployment-schedule"
+	RetryNowAnnotation              = ReleaseFinalizer + "/retry-now"
+	NamespaceOwnedLabel             = ReleaseFinalizer + "/owned"
+	ConfirmAdoptionAnnotation       = ReleaseFinalizer + "/confirm-adoption"
+	AllowedReleaseNamespaces        = ReleaseFinalizer + "/allowed-release-namespaces"
 	EnvVarNotificationProvider      = "NOTIFICATION_PROVIDER"
 	EnvVarNotificationProviderToken = "NOTIFICATION_PROVIDER_TOKEN"
+	DefaultKeyringSecretKey         = "pubring.gpg"
 )
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"github.com/coveros/genoa/pkg"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+const httpMaxAttempts = 4
+
+// httpRetryBaseDelay doubles after every failed attempt, a var so tests do not have to wait
+var httpRetryBaseDelay = time.Second
+
+// httpRetryBudget bounds the time spent retrying a request so a reconcile worker is not held up, anything longer is
+// left to the backoff of the controller
+var httpRetryBudget = 30 * time.Second
+
+// HTTPGet performs a single GET using basic auth when credentials are given, the caller must close the response body.
+// Failures are returned as one of the typed repo errors in pkg so callers can tell them apart.
+func HTTPGet(client *http.Client, url, username, password string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if username != "" && password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, classifyHTTPError(url, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, httpStatusError(url, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// DownloadFile downloads url into filepath, transient failures are retried with backoff
+func DownloadFile(client *http.Client, filepath, url, username, password string) error {
+	return RetryTransient(func() error {
+		resp, err := HTTPGet(client, url, username, password)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		// Create the file
+		out, err := os.Create(filepath)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		// Write the body to file
+		if _, err = io.Copy(out, resp.Body); err != nil {
+			return classifyHTTPError(url, err)
+		}
+		return nil
+	})
+}
+
+// RetryTransient calls fn until it succeeds, fails with a non transient error, runs out of attempts or the next attempt
+// would start after the retry budget. Timeouts are not retried here, they already took a whole request timeout.
+func RetryTransient(fn func() error) error {
+	start := time.Now()
+	delay := httpRetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsTransientHTTPError(err) || attempt == httpMaxAttempts {
+			return err
+		}
+		if _, timedOut := err.(pkg.ErrorRepoTimeout); timedOut || time.Since(start)+delay > httpRetryBudget {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// IsTransientHTTPError reports whether retrying the same request later might succeed
+func IsTransientHTTPError(err error) bool {
+	switch err.(type) {
+	case pkg.ErrorRepoServerError, pkg.ErrorRepoTimeout:
+		return true
+	}
+	return false
+}
+
+func httpStatusError(url string, statusCode int) error {
+	msg := fmt.Sprintf("GET %v: %v %v", url, statusCode, http.StatusText(statusCode))
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return pkg.ErrorRepoAuthFailed{Message: msg}
+	case statusCode == http.StatusNotFound:
+		return pkg.ErrorRepoNotFound{Message: msg}
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		return pkg.ErrorRepoServerError{Message: msg}
+	}
+	return pkg.ErrorRepoUnexpectedStatus{Message: msg}
+}
+
+func classifyHTTPError(url string, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return pkg.ErrorRepoTimeout{Message: fmt.Sprintf("GET %v: %v", url, err)}
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"github.com/coveros/genoa/pkg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDownloadFile(t *testing.T) {
+	httpRetryBaseDelay = time.Millisecond
+	tests := []struct {
+		name         string
+		statusCodes  []int
+		wantErr      error
+		wantAttempts int
+	}{
+		{name: "ok", statusCodes: []int{200}, wantAttempts: 1},
+		{name: "unauthorized", statusCodes: []int{401}, wantErr: pkg.ErrorRepoAuthFailed{}, wantAttempts: 1},
+		{name: "forbidden", statusCodes: []int{403}, wantErr: pkg.ErrorRepoAuthFailed{}, wantAttempts: 1},
+		{name: "not found", statusCodes: []int{404}, wantErr: pkg.ErrorRepoNotFound{}, wantAttempts: 1},
+		{name: "bad request", statusCodes: []int{400}, wantErr: pkg.ErrorRepoUnexpectedStatus{}, wantAttempts: 1},
+		{name: "recovers after server errors", statusCodes: []int{503, 502, 200}, wantAttempts: 3},
+		{name: "server error until out of attempts", statusCodes: []int{503}, wantErr: pkg.ErrorRepoServerError{}, wantAttempts: httpMaxAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				statusCode := tt.statusCodes[len(tt.statusCodes)-1]
+				if attempts < len(tt.statusCodes) {
+					statusCode = tt.statusCodes[attempts]
+				}
+				attempts++
+				w.WriteHeader(statusCode)
+				w.Write([]byte("chart"))
+			}))
+			defer server.Close()
+
+			dest, _ := ioutil.TempFile("", "chart-*.tgz")
+			dest.Close()
+			defer os.Remove(dest.Name())
+
+			err := DownloadFile(server.Client(), dest.Name(), server.URL, "", "")
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("DownloadFile() error = %v", err)
+			}
+			if tt.wantErr != nil && reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr) {
+				t.Errorf("DownloadFile() error = %T, want %T", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("DownloadFile() attempts = %v, want %v", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	defer func(baseDelay, budget time.Duration) {
+		httpRetryBaseDelay, httpRetryBudget = baseDelay, budget
+	}(httpRetryBaseDelay, httpRetryBudget)
+	httpRetryBaseDelay = 10 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		err          error
+		budget       time.Duration
+		wantAttempts int
+	}{
+		{name: "server error within the budget", err: pkg.ErrorRepoServerError{}, budget: time.Minute, wantAttempts: httpMaxAttempts},
+		{name: "server error past the budget", err: pkg.ErrorRepoServerError{}, budget: 25 * time.Millisecond, wantAttempts: 2},
+		{name: "timeout is left to the controller", err: pkg.ErrorRepoTimeout{}, budget: time.Minute, wantAttempts: 1},
+		{name: "not transient", err: pkg.ErrorRepoNotFound{}, budget: time.Minute, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpRetryBudget = tt.budget
+			attempts := 0
+			err := RetryTransient(func() error {
+				attempts++
+				return tt.err
+			})
+			if reflect.TypeOf(err) != reflect.TypeOf(tt.err) {
+				t.Errorf("RetryTransient() error = %T, want %T", err, tt.err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("RetryTransient() attempts = %v, want %v", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// NameWithSuffix appends suffix to name, keeping the result within maxLength. Names that would be too long are
+// truncated and get a short hash of the full name, so different long names still end up different.
+func NameWithSuffix(name, suffix string, maxLength int) string {
+	if len(name)+len(suffix) <= maxLength {
+		return name + suffix
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:8]
+	keep := maxLength - len(suffix) - len(hash) - 1
+	if keep < 0 {
+		keep = 0
+	}
+	truncated := strings.TrimRight(name[:keep], "-.")
+	return truncated + "-" + hash + suffix
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNameWithSuffix(t *testing.T) {
+	maxLengthName := strings.Repeat("a", 53)
+	tests := []struct {
+		name      string
+		base      string
+		wantExact string
+	}{
+		{name: "short name is kept", base: "jenkins", wantExact: "jenkins-canary"},
+		{name: "name that just fits is kept", base: strings.Repeat("a", 46), wantExact: strings.Repeat("a", 46) + "-canary"},
+		{name: "name one character too long", base: strings.Repeat("a", 47)},
+		{name: "maximum length helm release name", base: maxLengthName},
+		{name: "truncation does not leave a dash before the hash", base: strings.Repeat("a", 36) + "-" + strings.Repeat("b", 16)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NameWithSuffix(tt.base, "-canary", 53)
+			if tt.wantExact != "" && got != tt.wantExact {
+				t.Fatalf("NameWithSuffix() = %v, want %v", got, tt.wantExact)
+			}
+			if len(got) > 53 {
+				t.Errorf("NameWithSuffix() = %v is %v characters, want at most 53", got, len(got))
+			}
+			if !strings.HasSuffix(got, "-canary") || strings.Contains(got, "--") {
+				t.Errorf("NameWithSuffix() = %v", got)
+			}
+		})
+	}
+
+	// long names that only differ at the end must not share a canary
+	if NameWithSuffix(maxLengthName, "-canary", 53) == NameWithSuffix(strings.Repeat("a", 52)+"b", "-canary", 53) {
+		t.Errorf("NameWithSuffix() gave two long names the same result")
+	}
+}
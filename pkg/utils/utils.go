@@ -2,15 +2,12 @@ package utils
 
 import (
 	"context"
-	"errors"
 	"github.com/coveros/genoa/api/v1alpha1"
 	cNotifyLib "github.com/coveros/notification-library"
-	"io"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"net/http"
 	"os"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -63,41 +60,6 @@ func TrimSuffix(s, suffix string) string {
 	return s
 }
 
-func DownloadFile(filepath, url, username, password string) (err error) {
-
-	client := &http.Client{}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return err
-	}
-
-	if username != "" && password != "" {
-		req.SetBasicAuth(username, password)
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-
-	if resp.StatusCode != 200 {
-		return errors.New("StatusCodeNot200")
-	}
-
-	defer resp.Body.Close()
-
-	// Create the file
-	out, err := os.Create(filepath)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	return err
-
-}
-
 func GetChannelIDForNotification(runtimeObjMeta metav1.ObjectMeta) string {
 	channelToNotify := os.Getenv("DEFAULT_CHANNEL_ID")
 	if channelID, ok := runtimeObjMeta.Annotations[SlackChannelIDAnnotation]; ok {
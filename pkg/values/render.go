@@ -0,0 +1,129 @@
+package values
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Render executes the strings in values whose template actions refer to a top level field of data or one of funcs and
+// returns the result. Other templates, e.g. {{ .Values.x }} meant for the chart's own tpl, are left as they are, a
+// rendered string can keep a template for the chart as {{ "{{ .Values.x }}" }}.
+// Keys are never templated and the input is left untouched. Errors name the path of the value that failed.
+func Render(values map[string]interface{}, data interface{}, funcs template.FuncMap) (map[string]interface{}, error) {
+	rendered, err := render(values, "", data, funcs)
+	if err != nil {
+		return nil, err
+	}
+	if rendered == nil {
+		return nil, nil
+	}
+	return rendered.(map[string]interface{}), nil
+}
+
+func render(value interface{}, path string, data interface{}, funcs template.FuncMap) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return nil, nil
+		}
+		// sorted so the first error is always the same one
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		rendered := make(map[string]interface{}, len(v))
+		for _, key := range keys {
+			renderedValue, err := render(v[key], joinPath(path, key), data, funcs)
+			if err != nil {
+				return nil, err
+			}
+			rendered[key] = renderedValue
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			renderedValue, err := render(item, fmt.Sprintf("%s[%d]", path, i), data, funcs)
+			if err != nil {
+				return nil, err
+			}
+			rendered[i] = renderedValue
+		}
+		return rendered, nil
+	case string:
+		if !refersTo(v, data, funcs) {
+			return v, nil
+		}
+		tmpl, err := template.New(path).Funcs(funcs).Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("values.%s: %v", path, err)
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return nil, fmt.Errorf("values.%s: %v", path, err)
+		}
+		return out.String(), nil
+	}
+	return value, nil
+}
+
+// templateAction matches the template actions of a string, an unclosed one runs to the end
+var templateAction = regexp.MustCompile(`(?s){{.*?(}}|$)`)
+
+// refersTo reports whether a template action of text uses a top level field of data, e.g. .Release, or one of funcs
+func refersTo(text string, data interface{}, funcs template.FuncMap) bool {
+	if !strings.Contains(text, "{{") {
+		return false
+	}
+	var names []string
+	for name := range funcs {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	for _, field := range topLevelFields(data) {
+		names = append(names, `\.`+regexp.QuoteMeta(field))
+	}
+	if len(names) == 0 {
+		return false
+	}
+	reference := regexp.MustCompile(`(^|[^\w.$])(` + strings.Join(names, "|") + `)\b`)
+	for _, action := range templateAction.FindAllString(text, -1) {
+		if reference.MatchString(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// topLevelFields lists the fields of a struct or the keys of a map that templates can start from
+func topLevelFields(data interface{}) []string {
+	value := reflect.Indirect(reflect.ValueOf(data))
+	var fields []string
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if field := value.Type().Field(i); field.PkgPath == "" {
+				fields = append(fields, field.Name)
+			}
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			if key.Kind() == reflect.String {
+				fields = append(fields, key.String())
+			}
+		}
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
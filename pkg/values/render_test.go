@@ -0,0 +1,104 @@
+package values
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"text/template"
+)
+
+func TestRender(t *testing.T) {
+	data := map[string]interface{}{
+		"Cluster": map[string]interface{}{"Name": "prod", "Domain": "prod.example.com"},
+		"Release": map[string]interface{}{"Name": "jenkins", "Namespace": "ci"},
+	}
+	funcs := template.FuncMap{
+		"configMapKey": func(name, key string) (string, error) {
+			if name == "settings" && key == "replicas" {
+				return "3", nil
+			}
+			return "", errors.New("not found")
+		},
+	}
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "nested values and lists",
+			values: map[string]interface{}{
+				"ingress": map[string]interface{}{
+					"hosts":   []interface{}{"{{ .Release.Name }}.{{ .Cluster.Domain }}", "static.example.com"},
+					"enabled": true,
+				},
+				"replicas": "{{ configMapKey \"settings\" \"replicas\" }}",
+				"port":     8080,
+			},
+			want: map[string]interface{}{
+				"ingress": map[string]interface{}{
+					"hosts":   []interface{}{"jenkins.prod.example.com", "static.example.com"},
+					"enabled": true,
+				},
+				"replicas": "3",
+				"port":     8080,
+			},
+		},
+		{
+			name:   "nil values",
+			values: nil,
+			want:   nil,
+		},
+		{
+			name:    "missing key",
+			values:  map[string]interface{}{"a": map[string]interface{}{"b": "{{ .Cluster.Region }}"}},
+			wantErr: `values.a.b: template: a.b:1:11: executing "a.b" at <.Cluster.Region>: map has no entry for key "Region"`,
+		},
+		{
+			name:    "function error",
+			values:  map[string]interface{}{"a": "{{ configMapKey \"other\" \"key\" }}"},
+			wantErr: `values.a: template: a:1:3: executing "a" at <configMapKey "other" "key">: error calling configMapKey: not found`,
+		},
+		{
+			name: "templates for the chart are left alone",
+			values: map[string]interface{}{
+				"annotations": map[string]interface{}{"checksum": "{{ .Values.config | sha256sum }}", "release": "{{ .Release.Name }}"},
+				"host":        "{{ tpl .Values.hostTemplate . }}",
+				"labels":      "{{ include \"chart.labels\" $ }}",
+			},
+			want: map[string]interface{}{
+				"annotations": map[string]interface{}{"checksum": "{{ .Values.config | sha256sum }}", "release": "jenkins"},
+				"host":        "{{ tpl .Values.hostTemplate . }}",
+				"labels":      "{{ include \"chart.labels\" $ }}",
+			},
+		},
+		{
+			name:   "escaped template for the chart next to a rendered one",
+			values: map[string]interface{}{"host": `{{ .Release.Name }}-{{ "{{ .Values.suffix }}" }}`},
+			want:   map[string]interface{}{"host": "jenkins-{{ .Values.suffix }}"},
+		},
+		{
+			name:    "parse error",
+			values:  map[string]interface{}{"list": []interface{}{"{{ .Release.Name"}},
+			wantErr: `values.list[0]: template: list[0]:1: unclosed action`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.values, data, funcs)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Render() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}